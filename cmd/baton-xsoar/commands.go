@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/conductorone/baton-sdk/pkg/logging"
+	"github.com/conductorone/baton-xsoar/pkg/connector"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// The subcommands below run operations the connector protocol of the SDK has no call for. They read the same
+// configuration as the connector, from the config file, the environment and the flags.
+
+// loadConfig mirrors the configuration loading of the SDK's commands for the subcommands.
+func loadConfig(cmd *cobra.Command, cfg *config) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	cfgPath, cfgName := ".", ".baton"
+	if customPath := os.Getenv("BATON_CONFIG_PATH"); customPath != "" {
+		cfgDir, cfgFile := filepath.Split(filepath.Clean(customPath))
+		if cfgDir == "" {
+			cfgDir = "."
+		}
+
+		ext := filepath.Ext(cfgFile)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil, errors.New("expected config file to have .yaml or .yml extension")
+		}
+
+		cfgPath, cfgName = strings.TrimSuffix(cfgDir, string(filepath.Separator)), strings.TrimSuffix(cfgFile, ext)
+	}
+
+	v.SetConfigName(cfgName)
+	v.AddConfigPath(cfgPath)
+
+	if err := v.ReadInConfig(); err != nil {
+		if !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+			return nil, err
+		}
+	}
+
+	v.SetEnvPrefix("baton")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		return nil, err
+	}
+
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// requireProvisioning refuses to run a subcommand changing Cortex XSOAR unless provisioning is enabled, like the
+// SDK does for grants and revokes.
+func requireProvisioning(v *viper.Viper) error {
+	if !v.GetBool("provisioning") {
+		return errors.New("provisioning must be enabled with --provisioning to change Cortex XSOAR")
+	}
+
+	return nil
+}
+
+// setupCommand loads and validates the configuration, sets up logging and creates the connector.
+func setupCommand(ctx context.Context, cmd *cobra.Command, cfg *config) (context.Context, *viper.Viper, *connector.Xsoar, error) {
+	v, err := loadConfig(cmd, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	runCtx, err := logging.Init(
+		ctx,
+		logging.WithLogFormat(v.GetString("log-format")),
+		logging.WithLogLevel(v.GetString("log-level")),
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = validateConfig(runCtx, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	xs, err := newXsoar(runCtx, cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error creating connector: %w", err)
+	}
+
+	return runCtx, v, xs, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/conductorone/baton-sdk/pkg/cli"
+	"github.com/conductorone/baton-xsoar/pkg/connector"
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +19,27 @@ type config struct {
 	AccessToken string `mapstructure:"token"`
 	Unsafe      bool   `mapstructure:"unsafe"`
 	ApiUrl      string `mapstructure:"api-url"`
+	ApiKeyId    string `mapstructure:"api-key-id"`
+	ApiVersion  string `mapstructure:"api-version"`
+	ApiKeyType  string `mapstructure:"api-key-type"`
+
+	RetryMaxAttempts int           `mapstructure:"retry-max-attempts"`
+	RetryMaxElapsed  time.Duration `mapstructure:"retry-max-elapsed"`
+
+	RequestsPerSecond float64 `mapstructure:"requests-per-second"`
+	Burst             int     `mapstructure:"burst"`
+
+	PolicyFile     string   `mapstructure:"policy-file"`
+	ProtectedUsers []string `mapstructure:"protected-users"`
+	ProtectedRoles []string `mapstructure:"protected-roles"`
+
+	LastRoleAction string `mapstructure:"last-role-action"`
+	FloorRole      string `mapstructure:"floor-role"`
+
+	AllowBuiltinRoleChanges bool `mapstructure:"allow-builtin-role-changes"`
+
+	PasswordLength  int      `mapstructure:"password-length"`
+	PasswordClasses []string `mapstructure:"password-classes"`
 }
 
 // validateConfig is run after the configuration is loaded, and should return an error if it isn't valid.
@@ -35,6 +59,59 @@ func validateConfig(ctx context.Context, cfg *config) error {
 		return fmt.Errorf("the API URL must use the HTTPS scheme")
 	}
 
+	switch xsoar.ApiVersion(cfg.ApiVersion) {
+	case xsoar.ApiVersionAuto, xsoar.ApiVersion6:
+	case xsoar.ApiVersion8:
+		if cfg.ApiKeyId == "" {
+			return fmt.Errorf("an API key ID must be provided for Cortex XSOAR 8 and XSIAM tenants")
+		}
+	default:
+		return fmt.Errorf("the API version must be one of: auto, 6, 8")
+	}
+
+	switch xsoar.ApiKeyType(cfg.ApiKeyType) {
+	case xsoar.ApiKeyTypeStandard:
+	case xsoar.ApiKeyTypeAdvanced:
+		if cfg.ApiKeyId == "" {
+			return fmt.Errorf("an API key ID must be provided for advanced API keys")
+		}
+		// advanced keys are signed along with their ID, which API version 6 requests never carry
+		if xsoar.ApiVersion(cfg.ApiVersion) == xsoar.ApiVersion6 {
+			return fmt.Errorf("advanced API keys require API version 8 or auto")
+		}
+	default:
+		return fmt.Errorf("the API key type must be one of: standard, advanced")
+	}
+
+	if cfg.RetryMaxAttempts < 1 {
+		return fmt.Errorf("the maximum number of request attempts must be at least 1")
+	}
+	if cfg.RetryMaxElapsed < 0 {
+		return fmt.Errorf("the retry time budget must not be negative")
+	}
+
+	if cfg.RequestsPerSecond < 0 {
+		return fmt.Errorf("the number of requests per second must not be negative")
+	}
+	if cfg.RequestsPerSecond > 0 && cfg.Burst < 1 {
+		return fmt.Errorf("the request burst must be at least 1")
+	}
+
+	switch connector.LastRoleAction(cfg.LastRoleAction) {
+	case connector.LastRoleActionRefuse:
+	case connector.LastRoleActionFloorRole, connector.LastRoleActionDisable:
+		if cfg.FloorRole == "" {
+			return fmt.Errorf("a floor role must be provided when revoking the last role assigns the floor role or disables the user")
+		}
+	default:
+		return fmt.Errorf("the last role action must be one of: refuse, floor-role, disable")
+	}
+
+	err = connector.ValidatePasswordPolicy(cfg.PasswordLength, cfg.PasswordClasses)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -43,4 +120,19 @@ func cmdFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().String("token", "", "Access token used to connect to the Cortex XSOAR API. ($BATON_TOKEN)")
 	cmd.PersistentFlags().Bool("unsafe", false, "Allow insecure TLS connections to Cortex XSOAR instance. ($BATON_UNSAFE)")
 	cmd.PersistentFlags().String("api-url", "", "The API URL of the Cortex XSOAR instance. ($BATON_API_URL)")
+	cmd.PersistentFlags().String("api-key-id", "", "The ID of the API key, required for Cortex XSOAR 8 and XSIAM tenants. ($BATON_API_KEY_ID)")
+	cmd.PersistentFlags().String("api-key-type", string(xsoar.ApiKeyTypeStandard), "The security level of the API key: standard, advanced. ($BATON_API_KEY_TYPE)")
+	cmd.PersistentFlags().String("api-version", string(xsoar.ApiVersionAuto), "The Cortex XSOAR API version: auto, 6, 8. ($BATON_API_VERSION)")
+	cmd.PersistentFlags().Int("retry-max-attempts", xsoar.DefaultRetryMaxAttempts, "The maximum number of attempts for a failed request, 1 disables retries. ($BATON_RETRY_MAX_ATTEMPTS)")
+	cmd.PersistentFlags().Float64("requests-per-second", 0, "The maximum number of requests per second sent to the Cortex XSOAR API, 0 for no limit. ($BATON_REQUESTS_PER_SECOND)")
+	cmd.PersistentFlags().Int("burst", 1, "The number of requests which may be sent at once before the requests per second limit applies. ($BATON_BURST)")
+	cmd.PersistentFlags().String("last-role-action", string(connector.LastRoleActionRefuse), "What revoking the last role of a user does: refuse, floor-role, disable. ($BATON_LAST_ROLE_ACTION)")
+	cmd.PersistentFlags().String("floor-role", "", "ID or name of the role assigned in place of the last role of a user, e.g. a read-only role. ($BATON_FLOOR_ROLE)")
+	cmd.PersistentFlags().Bool("allow-builtin-role-changes", false, "Allow changing the permissions of the built-in Administrator, Analyst and Read-Only roles. ($BATON_ALLOW_BUILTIN_ROLE_CHANGES)")
+	cmd.PersistentFlags().Int("password-length", connector.DefaultPasswordLength, "The length of generated passwords. ($BATON_PASSWORD_LENGTH)")
+	cmd.PersistentFlags().StringSlice("password-classes", connector.DefaultPasswordClasses, "The character classes each generated password holds: lower, upper, digit, symbol. ($BATON_PASSWORD_CLASSES)")
+	cmd.PersistentFlags().String("policy-file", "", "Path to a YAML file with the provisioning policy: protected users and roles, and roles allowed or denied for grant and revoke. ($BATON_POLICY_FILE)")
+	cmd.PersistentFlags().StringSlice("protected-users", nil, "IDs or usernames of users whose roles are never changed, in addition to the policy file. ($BATON_PROTECTED_USERS)")
+	cmd.PersistentFlags().StringSlice("protected-roles", nil, "IDs or names of roles which are never granted or revoked, in addition to the policy file. ($BATON_PROTECTED_ROLES)")
+	cmd.PersistentFlags().Duration("retry-max-elapsed", xsoar.DefaultRetryMaxElapsed, "The time budget for all attempts of a request, 0 for no limit. ($BATON_RETRY_MAX_ELAPSED)")
 }
@@ -27,6 +27,16 @@ func main() {
 
 	cmd.Version = version
 	cmdFlags(cmd)
+	cmd.AddCommand(
+		rotateTokenCmd(ctx, cfg),
+		createRoleCmd(ctx, cfg),
+		deleteRoleCmd(ctx, cfg),
+		createUserCmd(ctx, cfg),
+		setUserStatusCmd(ctx, cfg, true),
+		setUserStatusCmd(ctx, cfg, false),
+		deleteUserCmd(ctx, cfg),
+		resetPasswordCmd(ctx, cfg),
+	)
 
 	err = cmd.Execute()
 	if err != nil {
@@ -38,7 +48,7 @@ func main() {
 func getConnector(ctx context.Context, cfg *config) (types.ConnectorServer, error) {
 	l := ctxzap.Extract(ctx)
 
-	xsoarConnector, err := connector.New(ctx, cfg.AccessToken, cfg.ApiUrl, cfg.Unsafe)
+	xsoarConnector, err := newXsoar(ctx, cfg)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
@@ -52,3 +62,32 @@ func getConnector(ctx context.Context, cfg *config) (types.ConnectorServer, erro
 
 	return connector, nil
 }
+
+func newXsoar(ctx context.Context, cfg *config) (*connector.Xsoar, error) {
+	return connector.New(ctx, connector.Config{
+		AccessToken: cfg.AccessToken,
+		ApiKeyId:    cfg.ApiKeyId,
+		ApiKeyType:  cfg.ApiKeyType,
+		ApiUrl:      cfg.ApiUrl,
+		ApiVersion:  cfg.ApiVersion,
+		Unsafe:      cfg.Unsafe,
+
+		RetryMaxAttempts: cfg.RetryMaxAttempts,
+		RetryMaxElapsed:  cfg.RetryMaxElapsed,
+
+		RequestsPerSecond: cfg.RequestsPerSecond,
+		Burst:             cfg.Burst,
+
+		PolicyFile:     cfg.PolicyFile,
+		ProtectedUsers: cfg.ProtectedUsers,
+		ProtectedRoles: cfg.ProtectedRoles,
+
+		LastRoleAction: cfg.LastRoleAction,
+		FloorRole:      cfg.FloorRole,
+
+		AllowBuiltinRoleChanges: cfg.AllowBuiltinRoleChanges,
+
+		PasswordLength:  cfg.PasswordLength,
+		PasswordClasses: cfg.PasswordClasses,
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func createRoleCmd(ctx context.Context, cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-role",
+		Short: "Create a role with the given permissions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			if err := requireProvisioning(v); err != nil {
+				return err
+			}
+
+			role, err := xs.CreateRole(runCtx, v.GetString("role-name"), v.GetStringSlice("role-permissions"))
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "created role %s with ID %s\n", role.Name, role.Id)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("role-name", "", "Name of the role to create. ($BATON_ROLE_NAME)")
+	cmd.Flags().StringSlice("role-permissions", nil, "Permissions of the role to create, at least one. ($BATON_ROLE_PERMISSIONS)")
+
+	return cmd
+}
+
+func deleteRoleCmd(ctx context.Context, cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete-role",
+		Short: "Delete a role no user holds, or every user when forced",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			if err := requireProvisioning(v); err != nil {
+				return err
+			}
+
+			role := v.GetString("role")
+			if role == "" {
+				return fmt.Errorf("the ID or name of the role to delete must be provided")
+			}
+
+			revoked, err := xs.DeleteRole(runCtx, role, v.GetBool("force"))
+			if len(revoked) > 0 {
+				fmt.Fprintf(os.Stderr, "revoked role %s from users: %s\n", role, strings.Join(revoked, ", "))
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "deleted role %s\n", role)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("role", "", "ID or name of the role to delete. ($BATON_ROLE)")
+	cmd.Flags().Bool("force", false, "Revoke the role from the users holding it before deleting it, as revoking it from each of them would. ($BATON_FORCE)")
+
+	return cmd
+}
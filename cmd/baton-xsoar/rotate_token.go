@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// stdoutSink selects standard output as the secret sink.
+const stdoutSink = "-"
+
+func rotateTokenCmd(ctx context.Context, cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-token",
+		Short: "Replace the API key of the connector with a new one and delete the old key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			if err := requireProvisioning(v); err != nil {
+				return err
+			}
+
+			if cfg.ApiKeyId == "" {
+				return fmt.Errorf("the API key ID of the current token must be provided to rotate it")
+			}
+
+			sink := v.GetString("secret-sink")
+			if sink == "" {
+				return fmt.Errorf("a secret sink must be provided to receive the new API key")
+			}
+
+			name := v.GetString("key-name")
+			if name == "" {
+				name = fmt.Sprintf("baton-xsoar-%s", time.Now().UTC().Format("20060102-150405"))
+			}
+
+			rotation, err := xs.RotateToken(runCtx, name, func(_ context.Context, secret string) error {
+				return writeSecret(sink, secret)
+			})
+			if rotation != nil {
+				// the new key ID is needed to rotate again, and is not a secret
+				fmt.Fprintf(os.Stderr, "new API key ID: %s\n", rotation.NewKeyId)
+			}
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("secret-sink", "", "Where the new API key is written: a file path, replaced with owner-only permissions, or - for standard output. ($BATON_SECRET_SINK)")
+	cmd.Flags().String("key-name", "", "Name of the new API key, defaults to baton-xsoar- followed by the current time. ($BATON_KEY_NAME)")
+
+	return cmd
+}
+
+// writeSecret writes a secret to the sink. Files are replaced at once, so readers never see a partial key.
+func writeSecret(sink, secret string) error {
+	if sink == stdoutSink {
+		_, err := fmt.Fprintln(os.Stdout, secret)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(sink), "."+filepath.Base(sink)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp already creates the file readable by its owner only
+	_, err = tmp.WriteString(secret + "\n")
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), sink)
+}
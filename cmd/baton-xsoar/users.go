@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/conductorone/baton-xsoar/pkg/connector"
+	"github.com/spf13/cobra"
+)
+
+func createUserCmd(ctx context.Context, cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-user",
+		Short: "Create a local user with a random initial password, or invite a user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			if err := requireProvisioning(v); err != nil {
+				return err
+			}
+
+			invite := v.GetBool("invite")
+			sink := v.GetString("secret-sink")
+			if !invite && sink == "" {
+				return fmt.Errorf("a secret sink must be provided to receive the initial password, or the user invited")
+			}
+
+			result, err := xs.CreateAccount(runCtx, &connector.AccountRequest{
+				Email:    v.GetString("user-email"),
+				Name:     v.GetString("user-name"),
+				Username: v.GetString("user-login"),
+				Roles:    v.GetStringSlice("user-roles"),
+				Invite:   invite,
+			})
+			if err != nil {
+				return err
+			}
+
+			if result.Resource == nil {
+				fmt.Fprintf(os.Stderr, "invited user, invitation ID: %s\n", result.InviteId)
+				return nil
+			}
+
+			fmt.Fprintf(os.Stderr, "created user %s\n", result.Resource.Id.Resource)
+
+			return writeSecret(sink, result.Password)
+		},
+	}
+
+	cmd.Flags().String("user-email", "", "Email of the user to create. ($BATON_USER_EMAIL)")
+	cmd.Flags().String("user-name", "", "Full name of the user to create. ($BATON_USER_NAME)")
+	cmd.Flags().String("user-login", "", "Username of the user to create, required unless invited. ($BATON_USER_LOGIN)")
+	cmd.Flags().StringSlice("user-roles", nil, "IDs or names of the roles of the user in the main account, the floor role if none. ($BATON_USER_ROLES)")
+	cmd.Flags().Bool("invite", false, "Invite the user by email instead of creating a local user, required for Cortex XSOAR 8. ($BATON_INVITE)")
+	cmd.Flags().String("secret-sink", "", "Where the initial password is written: a file path, replaced with owner-only permissions, or - for standard output. ($BATON_SECRET_SINK)")
+
+	return cmd
+}
+
+func setUserStatusCmd(ctx context.Context, cfg *config, disable bool) *cobra.Command {
+	use, short := "enable-user", "Enable a disabled user"
+	if disable {
+		use, short = "disable-user", "Disable a user at once, keeping their roles and investigation history"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			if err := requireProvisioning(v); err != nil {
+				return err
+			}
+
+			user := v.GetString("user")
+			if user == "" {
+				return fmt.Errorf("the ID or username of the user must be provided")
+			}
+
+			if disable {
+				_, err = xs.DisableUser(runCtx, user)
+			} else {
+				_, err = xs.EnableUser(runCtx, user)
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "%sd user %s\n", strings.TrimSuffix(use, "-user"), user)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("user", "", "ID or username of the user. ($BATON_USER)")
+
+	return cmd
+}
+
+func deleteUserCmd(ctx context.Context, cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete-user",
+		Short: "Delete a user after reassigning their incidents and tasks to a successor",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			dryRun := v.GetBool("dry-run")
+			if !dryRun {
+				if err := requireProvisioning(v); err != nil {
+					return err
+				}
+			}
+
+			user := v.GetString("user")
+			if user == "" {
+				return fmt.Errorf("the ID or username of the user must be provided")
+			}
+
+			summary, err := xs.DeleteUser(runCtx, user, v.GetString("successor"), dryRun)
+			if summary != nil {
+				// on failure the summary lists the incidents and tasks already reassigned
+				if encodeErr := json.NewEncoder(os.Stdout).Encode(summary); encodeErr != nil && err == nil {
+					err = encodeErr
+				}
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().String("user", "", "ID or username of the user. ($BATON_USER)")
+	cmd.Flags().String("successor", "", "ID or username of the user receiving the incidents and tasks, the manager of the user if empty. ($BATON_SUCCESSOR)")
+	cmd.Flags().Bool("dry-run", false, "Print the incidents and tasks which would be reassigned without changing anything. ($BATON_DRY_RUN)")
+
+	return cmd
+}
+
+func resetPasswordCmd(ctx context.Context, cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Set a new random password for a local user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runCtx, v, xs, err := setupCommand(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			if err := requireProvisioning(v); err != nil {
+				return err
+			}
+
+			user := v.GetString("user")
+			if user == "" {
+				return fmt.Errorf("the ID or username of the user must be provided")
+			}
+
+			sink := v.GetString("secret-sink")
+			if sink == "" {
+				return fmt.Errorf("a secret sink must be provided to receive the new password")
+			}
+
+			password, err := xs.ResetPassword(runCtx, user)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "reset password of user %s\n", user)
+
+			return writeSecret(sink, password)
+		},
+	}
+
+	cmd.Flags().String("user", "", "ID or username of the user. ($BATON_USER)")
+	cmd.Flags().String("secret-sink", "", "Where the new password is written: a file path, replaced with owner-only permissions, or - for standard output. ($BATON_SECRET_SINK)")
+
+	return cmd
+}
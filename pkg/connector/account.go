@@ -0,0 +1,91 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+)
+
+const mainAccountDisplayName = "Main account"
+
+type accountResourceType struct {
+	resourceType *v2.ResourceType
+	client       *xsoar.Client
+	memberships  *membershipIndex
+}
+
+func (a *accountResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return a.resourceType
+}
+
+// accountDisplayName returns the name of an account without the MSSP `acc_` prefix.
+func accountDisplayName(account string) string {
+	if account == xsoar.MainAccount {
+		return mainAccountDisplayName
+	}
+
+	return strings.TrimPrefix(account, xsoar.AccountPrefix)
+}
+
+// accountResource creates a new connector resource for a Xsoar account, the main tenant or an MSSP child account.
+func accountResource(account string) (*v2.Resource, error) {
+	return rs.NewResource(
+		accountDisplayName(account),
+		resourceTypeAccount,
+		account,
+		rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: resourceTypeRole.Id}),
+	)
+}
+
+func (a *accountResourceType) List(ctx context.Context, _ *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeAccount.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	accounts, err := a.memberships.allAccounts(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to list accounts: %w", err)
+	}
+
+	page, cursor := paginate(accounts, func(account string) string { return account }, bag.PageToken(), pageSize(pToken))
+
+	rv := make([]*v2.Resource, 0, len(page))
+	for _, account := range page {
+		ar, err := accountResource(account)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ar)
+	}
+
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(a.client), nil
+}
+
+func (a *accountResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (a *accountResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func accountBuilder(client *xsoar.Client, memberships *membershipIndex) *accountResourceType {
+	return &accountResourceType{
+		resourceType: resourceTypeAccount,
+		client:       client,
+		memberships:  memberships,
+	}
+}
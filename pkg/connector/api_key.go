@@ -0,0 +1,296 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const apiKeyOwner = "owner"
+
+type apiKeyResourceType struct {
+	resourceType *v2.ResourceType
+	client       *xsoar.Client
+	memberships  *membershipIndex
+	policy       *Policy
+}
+
+func (k *apiKeyResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return k.resourceType
+}
+
+// apiKeyDescription summarizes the creator and the creation and last use times of a key, as resources without a
+// trait carry no profile.
+func apiKeyDescription(key *xsoar.APIKey) string {
+	parts := []string{fmt.Sprintf("Xsoar API key created by %s", key.Username)}
+
+	if !key.Created.IsZero() {
+		parts = append(parts, fmt.Sprintf("created %s", key.Created.UTC().Format(time.RFC3339)))
+	}
+
+	if key.LastUsed != nil && !key.LastUsed.IsZero() {
+		parts = append(parts, fmt.Sprintf("last used %s", key.LastUsed.UTC().Format(time.RFC3339)))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// apiKeyResource creates a new connector resource for a Xsoar API key.
+func apiKeyResource(key *xsoar.APIKey) (*v2.Resource, error) {
+	displayName := key.Name
+	if displayName == "" {
+		displayName = key.Id
+	}
+
+	return rs.NewResource(
+		displayName,
+		resourceTypeAPIKey,
+		key.Id,
+		rs.WithDescription(apiKeyDescription(key)),
+	)
+}
+
+func (k *apiKeyResourceType) List(ctx context.Context, _ *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeAPIKey.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	keys, err := k.memberships.allAPIKeys(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to list api keys: %w", err)
+	}
+
+	page, cursor := paginate(keys, func(key xsoar.APIKey) string { return key.Id }, bag.PageToken(), pageSize(pToken))
+
+	rv := make([]*v2.Resource, 0, len(page))
+	for _, key := range page {
+		keyCopy := key
+
+		kr, err := apiKeyResource(&keyCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, kr)
+	}
+
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(k.client), nil
+}
+
+func (k *apiKeyResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlementOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeUser),
+		ent.WithDisplayName(fmt.Sprintf("%s API key owner", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("Owner of the %s Xsoar API key, the key acts with their roles", resource.DisplayName)),
+	}
+
+	return []*v2.Entitlement{
+		ent.NewPermissionEntitlement(resource, apiKeyOwner, entitlementOptions...),
+	}, "", nil, nil
+}
+
+// Grants links an API key to the user who created it.
+func (k *apiKeyResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	key, ownerId, err := k.memberships.apiKeyOwner(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to get api key owner: %w", err)
+	}
+
+	if key == nil {
+		return nil, "", nil, nil
+	}
+
+	if ownerId == "" {
+		l.Warn(
+			"xsoar-connector: api key owner not found",
+			zap.String("api_key_id", key.Id),
+			zap.String("username", key.Username),
+		)
+
+		return nil, "", nil, nil
+	}
+
+	metadata := map[string]interface{}{
+		"created_by": key.Username,
+	}
+	if !key.Created.IsZero() {
+		metadata["created_at"] = key.Created.UTC().Format(time.RFC3339)
+	}
+	if key.LastUsed != nil && !key.LastUsed.IsZero() {
+		metadata["last_used_at"] = key.LastUsed.UTC().Format(time.RFC3339)
+	}
+
+	return []*v2.Grant{
+		grant.NewGrant(
+			resource,
+			apiKeyOwner,
+			&v2.ResourceId{
+				ResourceType: resourceTypeUser.Id,
+				Resource:     ownerId,
+			},
+			grant.WithGrantMetadata(metadata),
+		),
+	}, "", rateLimitAnnotations(k.client), nil
+}
+
+// Grant is not supported: API keys are created by their owner, and their secret is only shown to them.
+func (k *apiKeyResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	l.Warn(
+		"xsoar-connector: api key ownership cannot be granted",
+		zap.String("principal_id", principal.Id.Resource),
+		zap.String("api_key_id", entitlement.Resource.Id.Resource),
+	)
+
+	return nil, status.Error(codes.Unimplemented, "xsoar-connector: api key ownership cannot be granted, keys are created by their owner")
+}
+
+// Revoke deletes the API key. The key the connector itself uses and the keys of protected users are never deleted.
+func (k *apiKeyResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	keyId := grant.Entitlement.Resource.Id.Resource
+	principal := grant.Principal
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"xsoar-connector: only users can own api keys",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: only users can own api keys")
+	}
+
+	// read the keys again rather than trusting the sync, the key may have been deleted since
+	keys, err := k.client.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get api keys: %w", err)
+	}
+
+	var key *xsoar.APIKey
+	for i := range keys {
+		if keys[i].Id == keyId {
+			key = &keys[i]
+			break
+		}
+	}
+
+	// TODO: annotate with GrantAlreadyRevoked once the SDK is upgraded
+	if key == nil {
+		l.Warn(
+			"xsoar-connector: api key already deleted",
+			zap.String("principal_id", principal.Id.Resource),
+			zap.String("api_key_id", keyId),
+		)
+
+		return nil, nil
+	}
+
+	owner, err := k.client.GetUser(ctx, principal.Id.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get user: %w", err)
+	}
+
+	if owner == nil || !strings.EqualFold(owner.Username, key.Username) {
+		l.Warn(
+			"xsoar-connector: api key is not owned by principal",
+			zap.String("principal_id", principal.Id.Resource),
+			zap.String("api_key_id", key.Id),
+			zap.String("username", key.Username),
+		)
+
+		return nil, status.Error(codes.FailedPrecondition, "xsoar-connector: api key is not owned by principal")
+	}
+
+	if err := k.checkNotOwnKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	if err := k.policy.authorizeAPIKeyDeletion(ctx, owner.Id, key); err != nil {
+		return nil, err
+	}
+
+	err = k.client.DeleteAPIKey(ctx, key.Id)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, fmt.Errorf("xsoar-connector: failed to delete api key: %w", err)
+	}
+
+	k.memberships.invalidate()
+
+	l.Info(
+		"xsoar-connector: deleted api key",
+		zap.String("principal_id", owner.Id),
+		zap.String("api_key_id", key.Id),
+		zap.String("api_key_name", key.Name),
+	)
+
+	return nil, nil
+}
+
+// checkNotOwnKey refuses to delete the key the connector is using. Without a configured API key ID the key in use
+// cannot be told apart from the other keys of the current user, so none of them is deleted.
+func (k *apiKeyResourceType) checkNotOwnKey(ctx context.Context, key *xsoar.APIKey) error {
+	l := ctxzap.Extract(ctx)
+
+	if k.client.ApiKeyId != "" {
+		if key.Id != k.client.ApiKeyId {
+			return nil
+		}
+
+		l.Warn(
+			"xsoar-connector: cannot delete the api key used by the connector",
+			zap.String("api_key_id", key.Id),
+		)
+
+		return status.Error(codes.PermissionDenied, "xsoar-connector: cannot delete the api key used by the connector")
+	}
+
+	currentUser, err := k.client.GetCurrentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("xsoar-connector: failed to get current user: %w", err)
+	}
+
+	if strings.EqualFold(currentUser.Username, key.Username) {
+		l.Warn(
+			"xsoar-connector: cannot delete api keys of the current user without an api key id",
+			zap.String("api_key_id", key.Id),
+			zap.String("current_user_id", currentUser.Id),
+		)
+
+		return status.Error(codes.PermissionDenied, "xsoar-connector: cannot delete api keys of the current user, the api key id of the connector is not configured")
+	}
+
+	return nil
+}
+
+func apiKeyBuilder(client *xsoar.Client, memberships *membershipIndex, policy *Policy) *apiKeyResourceType {
+	return &apiKeyResourceType{
+		resourceType: resourceTypeAPIKey,
+		client:       client,
+		memberships:  memberships,
+		policy:       policy,
+	}
+}
@@ -0,0 +1,66 @@
+package connector
+
+import (
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// roleCatalog maps role IDs, which role resources are keyed by, to role names, which users reference roles by.
+type roleCatalog struct {
+	names map[string]string
+	ids   map[string][]string
+}
+
+func newRoleCatalog(roles []xsoar.Role) *roleCatalog {
+	catalog := &roleCatalog{
+		names: make(map[string]string, len(roles)),
+		ids:   make(map[string][]string, len(roles)),
+	}
+
+	for _, role := range roles {
+		catalog.names[role.Id] = role.Name
+		catalog.ids[role.Name] = append(catalog.ids[role.Name], role.Id)
+	}
+
+	return catalog
+}
+
+// roleName resolves a role ID to the current name of the role. It fails when the role no longer exists, or when
+// its name is shared with another role, as user memberships could not be told apart then.
+func (c *roleCatalog) roleName(roleId string) (string, error) {
+	name, ok := c.names[roleId]
+	if !ok {
+		return "", status.Errorf(codes.NotFound, "xsoar-connector: role %s not found", roleId)
+	}
+
+	if len(c.ids[name]) > 1 {
+		return "", status.Errorf(
+			codes.FailedPrecondition,
+			"xsoar-connector: role %s is ambiguous, its name %q is shared by roles %v",
+			roleId,
+			name,
+			c.ids[name],
+		)
+	}
+
+	return name, nil
+}
+
+// lookup resolves a role given by ID or by name, as in the configuration, to its ID and name.
+func (c *roleCatalog) lookup(idOrName string) (string, string, error) {
+	if _, ok := c.names[idOrName]; ok {
+		name, err := c.roleName(idOrName)
+		return idOrName, name, err
+	}
+
+	ids := c.ids[idOrName]
+	switch len(ids) {
+	case 0:
+		return "", "", status.Errorf(codes.NotFound, "xsoar-connector: role %s not found", idOrName)
+	case 1:
+		return ids[0], idOrName, nil
+	default:
+		return "", "", status.Errorf(codes.FailedPrecondition, "xsoar-connector: role name %q is shared by roles %v", idOrName, ids)
+	}
+}
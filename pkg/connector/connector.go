@@ -3,6 +3,10 @@ package connector
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
@@ -30,23 +34,58 @@ var (
 			v2.ResourceType_TRAIT_ROLE,
 		},
 	}
+	resourceTypeAccount = &v2.ResourceType{
+		Id:          "account",
+		DisplayName: "Account",
+		Annotations: annotationsForAccountResourceType(),
+	}
+	resourceTypePermission = &v2.ResourceType{
+		Id:          "permission",
+		DisplayName: "Permission",
+	}
+	resourceTypeAPIKey = &v2.ResourceType{
+		Id:          "api_key",
+		DisplayName: "API Key",
+	}
 )
 
 type Xsoar struct {
-	client *xsoar.Client
+	client      *xsoar.Client
+	memberships *membershipIndex
+	policy      *Policy
+
+	lastRoleAction LastRoleAction
+	floorRole      string
+
+	allowBuiltinRoleChanges bool
+
+	passwordPolicy *PasswordPolicy
+
+	newClient func(token, apiKeyId string) (*xsoar.Client, error)
 }
 
 func (xs *Xsoar) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
 	return []connectorbuilder.ResourceSyncer{
-		userBuilder(xs.client),
-		roleBuilder(xs.client),
+		xs.userBuilder(),
+		xs.roleBuilder(),
+		accountBuilder(xs.client, xs.memberships),
+		permissionBuilder(xs.client, xs.memberships, xs.policy, xs.allowBuiltinRoleChanges),
+		apiKeyBuilder(xs.client, xs.memberships, xs.policy),
 	}
 }
 
+func (xs *Xsoar) userBuilder() *userResourceType {
+	return userBuilder(xs.client, xs.memberships, xs.policy, xs.floorRole, xs.passwordPolicy)
+}
+
+func (xs *Xsoar) roleBuilder() *roleResourceType {
+	return roleBuilder(xs.client, xs.memberships, xs.policy, xs.lastRoleAction, xs.floorRole)
+}
+
 func (xs *Xsoar) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 	return &v2.ConnectorMetadata{
 		DisplayName: "Xsoar",
-		Description: "Connector syncing Xsoar/Cortex XSOAR users and their roles to Baton.",
+		Description: "Connector syncing Xsoar/Cortex XSOAR users, accounts, their roles and permissions, and API keys to Baton.",
 	}, nil
 }
 
@@ -55,19 +94,63 @@ func (xs *Xsoar) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 func (xs *Xsoar) Validate(ctx context.Context) (annotations.Annotations, error) {
 	_, err := xs.client.GetCurrentUser(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "Provided Access Token is invalid - unable to get current user")
+		var apiErr *xsoar.ApiError
+		if errors.As(err, &apiErr) {
+			switch apiErr.StatusCode {
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return nil, fmt.Errorf("xsoar-connector: provided access token is invalid - unable to get current user: %w", err)
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("xsoar-connector: the API was not found, check the API URL and version - unable to get current user: %w", err)
+			default:
+				return nil, fmt.Errorf("xsoar-connector: unable to get current user: %w", err)
+			}
+		}
+
+		return nil, status.Errorf(codes.Unavailable, "xsoar-connector: unable to reach the Cortex XSOAR API: %s", err)
 	}
 
 	return nil, nil
 }
 
-func New(ctx context.Context, token, apiUrl string, unsafe bool) (*Xsoar, error) {
+// Config holds the options the connector is created with.
+type Config struct {
+	AccessToken string
+	ApiKeyId    string
+	ApiKeyType  string
+	ApiUrl      string
+	ApiVersion  string
+	Unsafe      bool
+
+	RetryMaxAttempts int
+	RetryMaxElapsed  time.Duration
+
+	RequestsPerSecond float64
+	Burst             int
+
+	// PolicyFile is a YAML file holding the provisioning Policy. ProtectedUsers and ProtectedRoles add to it.
+	PolicyFile     string
+	ProtectedUsers []string
+	ProtectedRoles []string
+
+	// LastRoleAction and FloorRole, given by ID or name, select what revoking a user's last role does.
+	LastRoleAction string
+	FloorRole      string
+
+	// AllowBuiltinRoleChanges allows changing the permissions of the Administrator, Analyst and Read-Only roles.
+	AllowBuiltinRoleChanges bool
+
+	// PasswordLength and PasswordClasses set how generated passwords look, see PasswordPolicy.
+	PasswordLength  int
+	PasswordClasses []string
+}
+
+func New(ctx context.Context, cfg Config) (*Xsoar, error) {
 	options := []uhttp.Option{
 		uhttp.WithLogger(true, ctxzap.Extract(ctx)),
 	}
 
 	// Skip TLS verification if flag `unsafe` is specified.
-	if unsafe {
+	if cfg.Unsafe {
 		options = append(
 			options,
 			uhttp.WithTLSClientConfig(
@@ -84,7 +167,58 @@ func New(ctx context.Context, token, apiUrl string, unsafe bool) (*Xsoar, error)
 		return nil, err
 	}
 
+	policy := &Policy{}
+	if cfg.PolicyFile != "" {
+		policy, err = LoadPolicy(cfg.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	policy.ProtectedUsers = append(policy.ProtectedUsers, cfg.ProtectedUsers...)
+	policy.ProtectedRoles = append(policy.ProtectedRoles, cfg.ProtectedRoles...)
+
+	// newClient is kept to build clients for rotated API keys with the same settings
+	newClient := func(token, apiKeyId string) (*xsoar.Client, error) {
+		signer, err := xsoar.NewSigner(xsoar.ApiKeyType(cfg.ApiKeyType), token)
+		if err != nil {
+			return nil, err
+		}
+
+		return xsoar.NewClient(
+			httpClient,
+			token,
+			cfg.ApiUrl,
+			xsoar.WithApiKeyId(apiKeyId),
+			xsoar.WithApiVersion(xsoar.ApiVersion(cfg.ApiVersion)),
+			xsoar.WithSigner(signer),
+			xsoar.WithRetryPolicy(xsoar.RetryPolicy{
+				MaxAttempts: cfg.RetryMaxAttempts,
+				MaxElapsed:  cfg.RetryMaxElapsed,
+			}),
+			xsoar.WithRateLimit(cfg.RequestsPerSecond, cfg.Burst),
+		), nil
+	}
+
+	client, err := newClient(cfg.AccessToken, cfg.ApiKeyId)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Xsoar{
-		client: xsoar.NewClient(httpClient, token, apiUrl),
+		client:      client,
+		memberships: newMembershipIndex(client),
+		policy:      policy,
+
+		lastRoleAction: LastRoleAction(cfg.LastRoleAction),
+		floorRole:      cfg.FloorRole,
+
+		allowBuiltinRoleChanges: cfg.AllowBuiltinRoleChanges,
+
+		passwordPolicy: &PasswordPolicy{
+			Length:  cfg.PasswordLength,
+			Classes: cfg.PasswordClasses,
+		},
+
+		newClient: newClient,
 	}, nil
 }
@@ -1,27 +1,149 @@
 package connector
 
 import (
+	"sort"
+	"strings"
+
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const ResourcesPageSize = 50
 
+// parsePageToken unmarshals a page token, starting a new pagination bag for the resource if it is empty.
+func parsePageToken(token string, resourceID *v2.ResourceId) (*pagination.Bag, error) {
+	b := &pagination.Bag{}
+	err := b.Unmarshal(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Current() == nil {
+		b.Push(pagination.PageState{
+			ResourceTypeID: resourceID.ResourceType,
+			ResourceID:     resourceID.Resource,
+		})
+	}
+
+	return b, nil
+}
+
+// pageSize returns the page size requested by the SDK, capped at ResourcesPageSize.
+func pageSize(pToken *pagination.Token) int {
+	if pToken == nil || pToken.Size <= 0 || pToken.Size > ResourcesPageSize {
+		return ResourcesPageSize
+	}
+
+	return pToken.Size
+}
+
+// paginate returns the page of items following the cursor and the cursor of the next page, empty on the last page.
+// Items must be sorted by ID. The cursor is the ID of the last item returned, so pages stay stable even when items
+// are added or removed between calls.
+func paginate[T any](items []T, id func(T) string, cursor string, size int) ([]T, string) {
+	start := 0
+	if cursor != "" {
+		start = sort.Search(len(items), func(i int) bool {
+			return id(items[i]) > cursor
+		})
+	}
+
+	end := start + size
+	if end >= len(items) {
+		return items[start:], ""
+	}
+
+	return items[start:end], id(items[end-1])
+}
+
 func annotationsForUserResourceType() annotations.Annotations {
 	annos := annotations.Annotations{}
 	annos.Update(&v2.SkipEntitlementsAndGrants{})
 	return annos
 }
 
-func flattenRoleNames(data map[string][]string) []string {
-	var roles []string
+func annotationsForAccountResourceType() annotations.Annotations {
+	annos := annotations.Annotations{}
+	annos.Update(&v2.SkipEntitlementsAndGrants{})
+	return annos
+}
+
+// roleResourceId returns the resource ID of a role within an account. Roles of the main account keep the plain
+// role ID, roles of MSSP child accounts are prefixed with the account, e.g. `acc_tenant-a/Analyst`.
+func roleResourceId(account, roleId string) string {
+	if account == xsoar.MainAccount {
+		return roleId
+	}
+
+	return account + "/" + roleId
+}
+
+// parseRoleResourceId splits a role resource ID into its account and role ID.
+func parseRoleResourceId(resourceId string) (string, string) {
+	if strings.HasPrefix(resourceId, xsoar.AccountPrefix) {
+		if account, roleId, ok := strings.Cut(resourceId, "/"); ok {
+			return account, roleId
+		}
+	}
+
+	return xsoar.MainAccount, resourceId
+}
+
+// rateLimitAnnotations reports the client's request budget to the SDK, so throttled syncs are visible to it.
+func rateLimitAnnotations(client *xsoar.Client) annotations.Annotations {
+	rateLimitStatus := client.RateLimitStatus()
+	if rateLimitStatus.Limit == 0 && !rateLimitStatus.Throttled {
+		return nil
+	}
+
+	description := &v2.RateLimitDescription{
+		Status:    v2.RateLimitDescription_STATUS_OK,
+		Limit:     rateLimitStatus.Limit,
+		Remaining: rateLimitStatus.Remaining,
+	}
+
+	if rateLimitStatus.Throttled {
+		description.Status = v2.RateLimitDescription_STATUS_OVERLIMIT
+	}
+
+	if !rateLimitStatus.ResetAt.IsZero() {
+		description.ResetAt = timestamppb.New(rateLimitStatus.ResetAt)
+	}
+
+	annos := annotations.Annotations{}
+	annos.WithRateLimiting(description)
+
+	return annos
+}
+
+// countRoles returns the number of roles held across all accounts, counting a role listed twice in an account once.
+func countRoles(data map[string][]string) int {
+	count := 0
 
 	for _, values := range data {
-		roles = append(roles, values...)
+		count += len(uniqueRoles(values))
 	}
 
-	return roles
+	return count
+}
+
+func uniqueRoles(roles []string) []string {
+	seen := make(map[string]struct{}, len(roles))
+	var rv []string
+
+	for _, role := range roles {
+		if _, ok := seen[role]; ok {
+			continue
+		}
+
+		seen[role] = struct{}{}
+		rv = append(rv, role)
+	}
+
+	return rv
 }
 
 func containsRole(roles []string, role string) bool {
@@ -34,18 +156,19 @@ func containsRole(roles []string, role string) bool {
 	return false
 }
 
-func findUser(users []xsoar.User, id string) *xsoar.User {
-	for _, user := range users {
-		if user.Id == id {
-			return &user
-		}
+// cloneRoles returns a copy of a user's per-account roles, safe to modify without touching the user.
+func cloneRoles(roles map[string][]string) map[string][]string {
+	rv := make(map[string][]string, len(roles))
+
+	for account, names := range roles {
+		rv[account] = append([]string{}, names...)
 	}
 
-	return nil
+	return rv
 }
 
 func removeRole(roles []string, targetRole string) []string {
-	var newRoles []string
+	newRoles := []string{}
 
 	for _, role := range roles {
 		if role == targetRole {
@@ -0,0 +1,291 @@
+package connector
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+)
+
+// membershipIndex holds the users of a sync, sorted by ID, and maps each role of each account to the IDs of the
+// users holding it. It is built from a single streamed pass over the users and reused for every page of users and
+// every role after that. Only the fields listed in userSummary are kept of each user, the roles only as members.
+type membershipIndex struct {
+	client *xsoar.Client
+
+	mu       sync.Mutex
+	built    bool
+	users    []userSummary
+	accounts []string
+	members  map[accountRole][]string
+	catalog  *roleCatalog
+	roles    []xsoar.Role
+
+	// API keys are fetched apart from the users, only when API keys are synced.
+	keysBuilt bool
+	keys      []xsoar.APIKey
+}
+
+// userSummary holds the fields of a user the index keeps, those userResource and API key owners need.
+type userSummary struct {
+	Id        string
+	Username  string
+	Name      string
+	FirstName string
+	LastName  string
+	Email     string
+	Disabled  bool
+}
+
+func newUserSummary(user *xsoar.User) userSummary {
+	return userSummary{
+		Id:        user.Id,
+		Username:  user.Username,
+		Name:      user.Name,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		Disabled:  user.Disabled,
+	}
+}
+
+// user returns the summary as a user without roles, for userResource.
+func (s *userSummary) user() *xsoar.User {
+	return &xsoar.User{
+		BaseResource: xsoar.BaseResource{Id: s.Id},
+		Username:     s.Username,
+		Name:         s.Name,
+		FirstName:    s.FirstName,
+		LastName:     s.LastName,
+		Email:        s.Email,
+		Disabled:     s.Disabled,
+	}
+}
+
+// accountRole identifies a role within an account.
+type accountRole struct {
+	account string
+	role    string
+}
+
+func newMembershipIndex(client *xsoar.Client) *membershipIndex {
+	return &membershipIndex{
+		client: client,
+	}
+}
+
+// build fetches the users if the index is empty. It must be called with the lock held.
+func (m *membershipIndex) build(ctx context.Context) error {
+	if m.built {
+		return nil
+	}
+
+	roles, err := m.client.GetRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	var users []userSummary
+	accounts := map[string]struct{}{
+		xsoar.MainAccount: {},
+	}
+	members := make(map[accountRole][]string)
+
+	err = m.client.StreamUsers(ctx, func(user xsoar.User) error {
+		for account, roles := range user.Roles {
+			accounts[account] = struct{}{}
+
+			for _, role := range uniqueRoles(roles) {
+				key := accountRole{account: account, role: role}
+				members[key] = append(members[key], user.Id)
+			}
+		}
+
+		users = append(users, newUserSummary(&user))
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+	for _, memberIds := range members {
+		sort.Strings(memberIds)
+	}
+
+	m.accounts = make([]string, 0, len(accounts))
+	for account := range accounts {
+		m.accounts = append(m.accounts, account)
+	}
+	sort.Strings(m.accounts)
+
+	m.users = users
+	m.members = members
+	m.catalog = newRoleCatalog(roles)
+	m.roles = roles
+	m.built = true
+
+	return nil
+}
+
+// allUsers returns the users sorted by ID, building the index if needed.
+func (m *membershipIndex) allUsers(ctx context.Context) ([]userSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.build(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.users, nil
+}
+
+// allAccounts returns the keys of the accounts any user has roles in, including the main account, sorted.
+func (m *membershipIndex) allAccounts(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.build(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.accounts, nil
+}
+
+// roleMembers returns the IDs of the users holding the role with the given ID in the given account sorted by ID,
+// building the index if needed.
+func (m *membershipIndex) roleMembers(ctx context.Context, account, roleId string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.build(ctx); err != nil {
+		return nil, err
+	}
+
+	roleName, err := m.catalog.roleName(roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.members[accountRole{account: account, role: roleName}], nil
+}
+
+// allPermissions returns the permissions held by any role, sorted and without duplicates.
+func (m *membershipIndex) allPermissions(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.build(ctx); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var permissions []string
+	for _, role := range m.roles {
+		for _, permission := range role.Permissions {
+			if _, ok := seen[permission]; ok {
+				continue
+			}
+
+			seen[permission] = struct{}{}
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+
+	return permissions, nil
+}
+
+// permissionRoles returns the IDs of the roles holding the permission, sorted.
+func (m *membershipIndex) permissionRoles(ctx context.Context, permission string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.build(ctx); err != nil {
+		return nil, err
+	}
+
+	var roleIds []string
+	for _, role := range m.roles {
+		if containsRole(role.Permissions, permission) {
+			roleIds = append(roleIds, role.Id)
+		}
+	}
+	sort.Strings(roleIds)
+
+	return roleIds, nil
+}
+
+// allAPIKeys returns the API keys sorted by ID, fetching them if needed.
+func (m *membershipIndex) allAPIKeys(ctx context.Context) ([]xsoar.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.keysBuilt {
+		keys, err := m.client.GetAPIKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Id < keys[j].Id
+		})
+
+		m.keys = keys
+		m.keysBuilt = true
+	}
+
+	return m.keys, nil
+}
+
+// apiKeyOwner returns the API key with the given ID and the ID of the user owning it, empty when the user no longer
+// exists. The key is nil when there is no key with that ID.
+func (m *membershipIndex) apiKeyOwner(ctx context.Context, keyId string) (*xsoar.APIKey, string, error) {
+	keys, err := m.allAPIKeys(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	i := sort.Search(len(keys), func(i int) bool {
+		return keys[i].Id >= keyId
+	})
+	if i == len(keys) || keys[i].Id != keyId {
+		return nil, "", nil
+	}
+
+	key := keys[i]
+
+	users, err := m.allUsers(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Username, key.Username) {
+			return &key, user.Id, nil
+		}
+	}
+
+	return &key, "", nil
+}
+
+// invalidate drops the index, so it is rebuilt from fresh data on next use. It is called when a new sync starts
+// listing users and after role memberships are changed.
+func (m *membershipIndex) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.built = false
+	m.users = nil
+	m.accounts = nil
+	m.members = nil
+	m.catalog = nil
+	m.roles = nil
+	m.keysBuilt = false
+	m.keys = nil
+}
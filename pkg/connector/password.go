@@ -0,0 +1,118 @@
+package connector
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+const (
+	DefaultPasswordLength = 24
+	// MinPasswordLength is the shortest password length a policy may set.
+	MinPasswordLength = 12
+
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#$%&*+-=?@^_~"
+)
+
+// PasswordClasses maps the character class names a password policy accepts to their characters.
+var PasswordClasses = map[string]string{
+	"lower":  lowerChars,
+	"upper":  upperChars,
+	"digit":  digitChars,
+	"symbol": symbolChars,
+}
+
+// DefaultPasswordClasses are the classes used when a policy names none.
+var DefaultPasswordClasses = []string{"lower", "upper", "digit", "symbol"}
+
+// PasswordPolicy sets the length of generated passwords and the character classes each of them holds.
+type PasswordPolicy struct {
+	Length  int
+	Classes []string
+}
+
+// ValidatePasswordPolicy checks the length and class names of a password policy.
+func ValidatePasswordPolicy(length int, classes []string) error {
+	if length < MinPasswordLength {
+		return fmt.Errorf("the password length must be at least %d", MinPasswordLength)
+	}
+
+	for _, class := range classes {
+		if _, ok := PasswordClasses[class]; !ok {
+			return fmt.Errorf("unknown password character class %q, must be one of: lower, upper, digit, symbol", class)
+		}
+	}
+
+	return nil
+}
+
+// generate returns a random password following the policy.
+func (p *PasswordPolicy) generate() (string, error) {
+	classes := p.Classes
+	if len(classes) == 0 {
+		classes = DefaultPasswordClasses
+	}
+
+	if err := ValidatePasswordPolicy(p.Length, classes); err != nil {
+		return "", fmt.Errorf("xsoar-connector: invalid password policy: %w", err)
+	}
+
+	charsets := make([]string, 0, len(classes))
+	for _, class := range classes {
+		charsets = append(charsets, PasswordClasses[class])
+	}
+
+	return generatePassword(p.Length, charsets)
+}
+
+// generatePassword returns a random password holding at least one character of each class, drawn using a CSPRNG.
+func generatePassword(length int, classes []string) (string, error) {
+	var all string
+	for _, class := range classes {
+		all += class
+	}
+
+	out := make([]byte, 0, length)
+
+	// one character of each class first, then any, shuffled below so the classes are not at fixed positions
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+
+		out = append(out, c)
+	}
+
+	for len(out) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+
+		out = append(out, c)
+	}
+
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
+	}
+
+	return string(out), nil
+}
+
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+
+	return charset[n.Int64()], nil
+}
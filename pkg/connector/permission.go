@@ -0,0 +1,337 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const permissionAssigned = "assigned"
+
+// builtinRoles are the roles Cortex XSOAR ships with. Their permissions are only changed when explicitly allowed.
+var builtinRoles = []string{"Administrator", "Analyst", "Read-Only"}
+
+type permissionResourceType struct {
+	resourceType *v2.ResourceType
+	client       *xsoar.Client
+	memberships  *membershipIndex
+	policy       *Policy
+
+	allowBuiltinRoleChanges bool
+}
+
+func (p *permissionResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return p.resourceType
+}
+
+// permissionResource creates a new connector resource for a Xsoar permission, such as `playbooks.edit`.
+func permissionResource(permission string) (*v2.Resource, error) {
+	return rs.NewResource(
+		permission,
+		resourceTypePermission,
+		permission,
+	)
+}
+
+func (p *permissionResourceType) List(ctx context.Context, _ *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypePermission.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	permissions, err := p.memberships.allPermissions(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to list permissions: %w", err)
+	}
+
+	page, cursor := paginate(permissions, func(permission string) string { return permission }, bag.PageToken(), pageSize(pToken))
+
+	rv := make([]*v2.Resource, 0, len(page))
+	for _, permission := range page {
+		pr, err := permissionResource(permission)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, pr)
+	}
+
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(p.client), nil
+}
+
+func (p *permissionResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlementOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeRole),
+		ent.WithDisplayName(fmt.Sprintf("%s permission", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("%s Xsoar permission, held by roles", resource.DisplayName)),
+	}
+
+	return []*v2.Entitlement{
+		ent.NewPermissionEntitlement(resource, permissionAssigned, entitlementOptions...),
+	}, "", nil, nil
+}
+
+// Grants emits a grant for every role holding the permission, in every account the role is listed in. Users hold the
+// permission through their role memberships.
+func (p *permissionResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(pToken.Token, resource.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	roleIds, err := p.memberships.permissionRoles(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to get permission holders: %w", err)
+	}
+
+	accounts, err := p.memberships.allAccounts(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to list accounts: %w", err)
+	}
+
+	roleResourceIds := make([]string, 0, len(roleIds)*len(accounts))
+	for _, account := range accounts {
+		for _, roleId := range roleIds {
+			roleResourceIds = append(roleResourceIds, roleResourceId(account, roleId))
+		}
+	}
+	sort.Strings(roleResourceIds)
+
+	page, cursor := paginate(roleResourceIds, func(roleResourceID string) string { return roleResourceID }, bag.PageToken(), pageSize(pToken))
+
+	rv := make([]*v2.Grant, 0, len(page))
+	for _, roleResourceID := range page {
+		account, _ := parseRoleResourceId(roleResourceID)
+
+		rv = append(rv, grant.NewGrant(
+			resource,
+			permissionAssigned,
+			&v2.ResourceId{
+				ResourceType: resourceTypeRole.Id,
+				Resource:     roleResourceID,
+			},
+			grant.WithGrantMetadata(map[string]interface{}{
+				"account": account,
+			}),
+		))
+	}
+
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(p.client), nil
+}
+
+// Grant adds the permission to a role, in every account the role is listed in.
+func (p *permissionResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	permission := entitlement.Resource.Id.Resource
+
+	err := p.updateRole(ctx, principal, func(role *xsoar.Role) (bool, error) {
+		if containsRole(role.Permissions, permission) {
+			// TODO: annotate with GrantAlreadyExists once the SDK is upgraded
+			l.Warn(
+				"xsoar-connector: role already holds permission",
+				zap.String("role_id", role.Id),
+				zap.String("permission", permission),
+			)
+
+			return false, nil
+		}
+
+		role.Permissions = append(role.Permissions, permission)
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Revoke removes the permission from a role, in every account the role is listed in. The last permission of a role is
+// never removed.
+func (p *permissionResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	permission := grant.Entitlement.Resource.Id.Resource
+
+	err := p.updateRole(ctx, grant.Principal, func(role *xsoar.Role) (bool, error) {
+		if !containsRole(role.Permissions, permission) {
+			// TODO: annotate with GrantAlreadyRevoked once the SDK is upgraded
+			l.Warn(
+				"xsoar-connector: role already lacks permission",
+				zap.String("role_id", role.Id),
+				zap.String("permission", permission),
+			)
+
+			return false, nil
+		}
+
+		remaining := removeRole(role.Permissions, permission)
+		if len(remaining) == 0 {
+			l.Warn(
+				"xsoar-connector: cannot revoke last permission of role",
+				zap.String("role_id", role.Id),
+				zap.String("permission", permission),
+			)
+
+			return false, status.Error(codes.FailedPrecondition, "xsoar-connector: cannot revoke last permission of role")
+		}
+
+		role.Permissions = remaining
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// updateRole reads the role the principal stands for, applies change and sends the role back along with the version
+// it read. When change reports no change, nothing is sent. Roles updated concurrently are read again, up to
+// maxRoleUpdateAttempts times, like users in updateUser.
+func (p *permissionResourceType) updateRole(ctx context.Context, principal *v2.Resource, change func(role *xsoar.Role) (bool, error)) error {
+	l := ctxzap.Extract(ctx)
+
+	if principal.Id.ResourceType != resourceTypeRole.Id {
+		l.Warn(
+			"xsoar-connector: only roles can hold permissions",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+
+		return status.Error(codes.InvalidArgument, "xsoar-connector: only roles can hold permissions, users hold them through roles")
+	}
+
+	_, roleId := parseRoleResourceId(principal.Id.Resource)
+
+	for attempt := 1; attempt <= maxRoleUpdateAttempts; attempt++ {
+		roles, err := p.client.GetRoles(ctx)
+		if err != nil {
+			return fmt.Errorf("xsoar-connector: failed to get roles: %w", err)
+		}
+
+		var role *xsoar.Role
+		for i := range roles {
+			if roles[i].Id == roleId {
+				role = &roles[i]
+				break
+			}
+		}
+
+		if role == nil {
+			return status.Errorf(codes.NotFound, "xsoar-connector: role %s not found", roleId)
+		}
+
+		if err := p.checkRoleEditable(ctx, role); err != nil {
+			return err
+		}
+
+		changed, err := change(role)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			return nil
+		}
+
+		err = p.client.UpdateRole(ctx, role)
+		if err == nil {
+			p.memberships.invalidate()
+
+			l.Info(
+				"xsoar-connector: updated role permissions",
+				zap.String("role_id", role.Id),
+				zap.String("role", role.Name),
+				zap.Strings("permissions", role.Permissions),
+			)
+
+			return nil
+		}
+
+		if !xsoar.IsVersionConflict(err) {
+			return fmt.Errorf("xsoar-connector: failed to update role: %w", err)
+		}
+
+		l.Warn(
+			"xsoar-connector: role was modified concurrently, retrying permission update",
+			zap.String("role_id", role.Id),
+			zap.Int("version", role.Version),
+			zap.Int("attempt", attempt),
+		)
+	}
+
+	return status.Errorf(
+		codes.Aborted,
+		"xsoar-connector: role %s kept being modified concurrently, permission update gave up after %d attempts",
+		roleId,
+		maxRoleUpdateAttempts,
+	)
+}
+
+// checkRoleEditable refuses changes to built-in roles, unless allowed, and to protected roles.
+func (p *permissionResourceType) checkRoleEditable(ctx context.Context, role *xsoar.Role) error {
+	l := ctxzap.Extract(ctx)
+
+	if !p.allowBuiltinRoleChanges && matchesAny(builtinRoles, role.Id, role.Name) {
+		l.Warn(
+			"xsoar-connector: cannot change permissions of built-in role",
+			zap.String("role_id", role.Id),
+			zap.String("role", role.Name),
+		)
+
+		return status.Errorf(codes.PermissionDenied, "xsoar-connector: cannot change permissions of built-in role %s", role.Name)
+	}
+
+	if matchesAny(p.policy.ProtectedRoles, role.Id, role.Name) {
+		l.Warn(
+			"xsoar-connector: cannot change permissions of protected role",
+			zap.String("role_id", role.Id),
+			zap.String("role", role.Name),
+		)
+
+		return status.Errorf(codes.PermissionDenied, "xsoar-connector: cannot change permissions of protected role %s", role.Name)
+	}
+
+	return nil
+}
+
+func permissionBuilder(
+	client *xsoar.Client,
+	memberships *membershipIndex,
+	policy *Policy,
+	allowBuiltinRoleChanges bool,
+) *permissionResourceType {
+	return &permissionResourceType{
+		resourceType:            resourceTypePermission,
+		client:                  client,
+		memberships:             memberships,
+		policy:                  policy,
+		allowBuiltinRoleChanges: allowBuiltinRoleChanges,
+	}
+}
@@ -0,0 +1,200 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+type policyAction string
+
+const (
+	policyActionGrant  policyAction = "grant"
+	policyActionRevoke policyAction = "revoke"
+)
+
+// userAction is a change to a user account itself, rather than to its role memberships.
+type userAction string
+
+const (
+	userActionDisable userAction = "disable"
+	userActionEnable  userAction = "enable"
+	userActionDelete  userAction = "delete"
+	// userActionResetPassword reads "policy denies password reset of user".
+	userActionResetPassword userAction = "password reset"
+)
+
+// Policy decides which role memberships the connector may change. Users and roles are matched by ID or by name,
+// ignoring case. It is loaded from a YAML file such as:
+//
+//	protected-users: [breakglass, soc-lead]
+//	protected-roles: [Break Glass]
+//	grant:
+//	  deny: [Administrator]
+//	revoke:
+//	  allow: [Analyst, Read-Only]
+type Policy struct {
+	// ProtectedUsers can neither be granted nor revoked any role. The default admin user is always protected.
+	ProtectedUsers []string `yaml:"protected-users"`
+	// ProtectedRoles can neither be granted to nor revoked from anyone.
+	ProtectedRoles []string `yaml:"protected-roles"`
+
+	Grant  RoleRules `yaml:"grant"`
+	Revoke RoleRules `yaml:"revoke"`
+}
+
+// RoleRules restrict the roles an action applies to. Deny wins over Allow; an empty Allow allows every role.
+type RoleRules struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// LoadPolicy reads a policy from a YAML file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to read policy file: %w", err)
+	}
+
+	policy := &Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(policy)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to parse policy file %s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+// matchesAny reports whether any of the candidates, usually an ID and a name, is in the list.
+func matchesAny(list []string, candidates ...string) bool {
+	for _, item := range list {
+		for _, candidate := range candidates {
+			if candidate != "" && strings.EqualFold(item, candidate) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// protectsUser reports whether the user, given by ID and username, is protected.
+func (p *Policy) protectsUser(userId, username string) bool {
+	return userId == defaultAdminUser || matchesAny(p.ProtectedUsers, userId, username)
+}
+
+// denial returns why the policy refuses the change, or an empty string if it allows it.
+func (p *Policy) denial(action policyAction, user *xsoar.User, roleId, roleName string) string {
+	if p.protectsUser(user.Id, user.Username) {
+		return "user is protected"
+	}
+
+	if matchesAny(p.ProtectedRoles, roleId, roleName) {
+		return "role is protected"
+	}
+
+	rules := p.Grant
+	if action == policyActionRevoke {
+		rules = p.Revoke
+	}
+
+	if matchesAny(rules.Deny, roleId, roleName) {
+		return fmt.Sprintf("role is denied for %s", action)
+	}
+
+	if len(rules.Allow) > 0 && !matchesAny(rules.Allow, roleId, roleName) {
+		return fmt.Sprintf("role is not allowed for %s", action)
+	}
+
+	return ""
+}
+
+// authorize checks a role membership change against the policy, and logs the decision either way.
+func (p *Policy) authorize(ctx context.Context, action policyAction, user *xsoar.User, account, roleId, roleName string) error {
+	l := ctxzap.Extract(ctx)
+
+	fields := []zap.Field{
+		zap.String("action", string(action)),
+		zap.String("principal_id", user.Id),
+		zap.String("username", user.Username),
+		zap.String("account", account),
+		zap.String("role_id", roleId),
+		zap.String("role", roleName),
+	}
+
+	reason := p.denial(action, user, roleId, roleName)
+	if reason != "" {
+		l.Warn("xsoar-connector: policy denied role membership change", append(fields, zap.String("reason", reason))...)
+
+		return status.Errorf(codes.PermissionDenied, "xsoar-connector: policy denies %s of role %s for user %s: %s", action, roleName, user.Id, reason)
+	}
+
+	l.Info("xsoar-connector: policy allowed role membership change", fields...)
+
+	return nil
+}
+
+// authorizeAPIKeyDeletion checks the deletion of an API key against the protected users, and logs the decision
+// either way. The keys of protected users are never deleted.
+func (p *Policy) authorizeAPIKeyDeletion(ctx context.Context, ownerId string, key *xsoar.APIKey) error {
+	l := ctxzap.Extract(ctx)
+
+	fields := []zap.Field{
+		zap.String("principal_id", ownerId),
+		zap.String("username", key.Username),
+		zap.String("api_key_id", key.Id),
+		zap.String("api_key_name", key.Name),
+	}
+
+	if p.protectsUser(ownerId, key.Username) {
+		l.Warn("xsoar-connector: policy denied api key deletion", append(fields, zap.String("reason", "owner is protected"))...)
+
+		return status.Errorf(codes.PermissionDenied, "xsoar-connector: policy denies deletion of api key %s of user %s: owner is protected", key.Id, key.Username)
+	}
+
+	l.Info("xsoar-connector: policy allowed api key deletion", fields...)
+
+	return nil
+}
+
+// authorizeUserChange checks a change to a user account against the policy, and logs the decision either way. The
+// connector's own user, given by currentUserId, and protected users are never changed.
+func (p *Policy) authorizeUserChange(ctx context.Context, action userAction, currentUserId string, user *xsoar.User) error {
+	l := ctxzap.Extract(ctx)
+
+	fields := []zap.Field{
+		zap.String("action", string(action)),
+		zap.String("principal_id", user.Id),
+		zap.String("username", user.Username),
+	}
+
+	var reason string
+	switch {
+	case user.Id == currentUserId:
+		reason = "user is the connector's own user"
+	case p.protectsUser(user.Id, user.Username):
+		reason = "user is protected"
+	}
+
+	if reason != "" {
+		l.Warn("xsoar-connector: policy denied user change", append(fields, zap.String("reason", reason))...)
+
+		return status.Errorf(codes.PermissionDenied, "xsoar-connector: policy denies %s of user %s: %s", action, user.Id, reason)
+	}
+
+	l.Info("xsoar-connector: policy allowed user change", fields...)
+
+	return nil
+}
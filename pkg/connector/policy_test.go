@@ -0,0 +1,188 @@
+package connector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+)
+
+func TestPolicyDenial(t *testing.T) {
+	user := &xsoar.User{BaseResource: xsoar.BaseResource{Id: "user-1"}, Username: "jdoe"}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		action   policyAction
+		user     *xsoar.User
+		roleId   string
+		roleName string
+		want     string
+	}{
+		{
+			name:     "empty policy allows",
+			action:   policyActionGrant,
+			user:     user,
+			roleId:   "role-analyst",
+			roleName: "Analyst",
+		},
+		{
+			name:     "default admin user is always protected",
+			action:   policyActionRevoke,
+			user:     &xsoar.User{BaseResource: xsoar.BaseResource{Id: defaultAdminUser}, Username: defaultAdminUser},
+			roleId:   "role-analyst",
+			roleName: "Analyst",
+			want:     "user is protected",
+		},
+		{
+			name:     "protected user matched by id ignoring case",
+			policy:   Policy{ProtectedUsers: []string{"USER-1"}},
+			action:   policyActionGrant,
+			user:     user,
+			roleName: "Analyst",
+			want:     "user is protected",
+		},
+		{
+			name:     "protected user matched by username ignoring case",
+			policy:   Policy{ProtectedUsers: []string{"JDoe"}},
+			action:   policyActionGrant,
+			user:     user,
+			roleName: "Analyst",
+			want:     "user is protected",
+		},
+		{
+			name:     "protected role matched by id",
+			policy:   Policy{ProtectedRoles: []string{"role-break-glass"}},
+			action:   policyActionRevoke,
+			user:     user,
+			roleId:   "role-break-glass",
+			roleName: "Break Glass",
+			want:     "role is protected",
+		},
+		{
+			name:     "protected role matched by name ignoring case",
+			policy:   Policy{ProtectedRoles: []string{"break glass"}},
+			action:   policyActionGrant,
+			user:     user,
+			roleId:   "role-break-glass",
+			roleName: "Break Glass",
+			want:     "role is protected",
+		},
+		{
+			name:     "deny wins over allow",
+			policy:   Policy{Grant: RoleRules{Allow: []string{"Administrator"}, Deny: []string{"administrator"}}},
+			action:   policyActionGrant,
+			user:     user,
+			roleId:   "role-admin",
+			roleName: "Administrator",
+			want:     "role is denied for grant",
+		},
+		{
+			name:     "role missing from allow list",
+			policy:   Policy{Revoke: RoleRules{Allow: []string{"Analyst", "Read-Only"}}},
+			action:   policyActionRevoke,
+			user:     user,
+			roleId:   "role-admin",
+			roleName: "Administrator",
+			want:     "role is not allowed for revoke",
+		},
+		{
+			name:     "role in allow list by id",
+			policy:   Policy{Revoke: RoleRules{Allow: []string{"role-analyst"}}},
+			action:   policyActionRevoke,
+			user:     user,
+			roleId:   "role-analyst",
+			roleName: "Analyst",
+		},
+		{
+			name:     "empty allow list allows every role not denied",
+			policy:   Policy{Grant: RoleRules{Deny: []string{"Administrator"}}},
+			action:   policyActionGrant,
+			user:     user,
+			roleId:   "role-analyst",
+			roleName: "Analyst",
+		},
+		{
+			name:     "rules of the other action do not apply",
+			policy:   Policy{Grant: RoleRules{Deny: []string{"Analyst"}}},
+			action:   policyActionRevoke,
+			user:     user,
+			roleId:   "role-analyst",
+			roleName: "Analyst",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.denial(tt.action, tt.user, tt.roleId, tt.roleName); got != tt.want {
+				t.Errorf("denial() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+		check   func(t *testing.T, policy *Policy)
+	}{
+		{
+			name: "valid policy",
+			content: `protected-users: [breakglass]
+protected-roles: [Break Glass]
+grant:
+  deny: [Administrator]
+revoke:
+  allow: [Analyst, Read-Only]
+`,
+			check: func(t *testing.T, policy *Policy) {
+				if len(policy.ProtectedUsers) != 1 || policy.ProtectedUsers[0] != "breakglass" {
+					t.Errorf("unexpected protected users: %v", policy.ProtectedUsers)
+				}
+				if len(policy.Grant.Deny) != 1 || len(policy.Revoke.Allow) != 2 {
+					t.Errorf("unexpected rules: grant %+v, revoke %+v", policy.Grant, policy.Revoke)
+				}
+			},
+		},
+		{
+			name:    "unknown top level key",
+			content: "protected-user: [breakglass]\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown rule key",
+			content: "grant:\n  denied: [Administrator]\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			content: "grant: [\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write policy file: %v", err)
+			}
+
+			policy, err := LoadPolicy(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPolicy() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if tt.check != nil {
+				tt.check(t, policy)
+			}
+		})
+	}
+
+	if _, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadPolicy() of a missing file succeeded")
+	}
+}
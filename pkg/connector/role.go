@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -14,34 +15,59 @@ import (
 	"github.com/conductorone/baton-xsoar/pkg/xsoar"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const roleMember = "member"
 const defaultAdminUser = "admin"
+const maxRoleUpdateAttempts = 3
 
 type roleResourceType struct {
 	resourceType *v2.ResourceType
 	client       *xsoar.Client
+	memberships  *membershipIndex
+	policy       *Policy
+
+	lastRoleAction LastRoleAction
+	floorRole      string
 }
 
+// LastRoleAction selects what revoking the only role a user has left does, as XSOAR users need at least one role.
+type LastRoleAction string
+
+const (
+	// LastRoleActionRefuse fails the revoke.
+	LastRoleActionRefuse LastRoleAction = "refuse"
+	// LastRoleActionFloorRole replaces the role with the floor role in the same update.
+	LastRoleActionFloorRole LastRoleAction = "floor-role"
+	// LastRoleActionDisable disables the user and replaces the role with the floor role in the same update.
+	LastRoleActionDisable LastRoleAction = "disable"
+)
+
 func (r *roleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return r.resourceType
 }
 
-// roleResource creates a new connector resource for a Xsoar Role.
-func roleResource(ctx context.Context, role *xsoar.Role) (*v2.Resource, error) {
+// roleResource creates a new connector resource for a Xsoar Role within an account.
+func roleResource(ctx context.Context, account string, role *xsoar.Role) (*v2.Resource, error) {
 	rolePermissionsString := strings.Join(role.Permissions, ",")
 	profile := map[string]interface{}{
 		"role_id":          role.Id,
 		"role_name":        role.Name,
 		"role_permissions": rolePermissionsString,
+		"account":          account,
 	}
 
 	resource, err := rs.NewRoleResource(
 		role.Name,
 		resourceTypeRole,
-		role.Id,
+		roleResourceId(account, role.Id),
 		[]rs.RoleTraitOption{rs.WithRoleProfile(profile)},
+		rs.WithParentResourceID(&v2.ResourceId{
+			ResourceType: resourceTypeAccount.Id,
+			Resource:     account,
+		}),
 	)
 	if err != nil {
 		return nil, err
@@ -50,17 +76,35 @@ func roleResource(ctx context.Context, role *xsoar.Role) (*v2.Resource, error) {
 	return resource, nil
 }
 
-func (r *roleResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+// List lists the roles of an account. Roles only exist as children of accounts, so nothing is listed without one.
+func (r *roleResourceType) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeAccount.Id {
+		return nil, "", nil, nil
+	}
+
+	account := parentResourceID.Resource
+
+	bag, err := parsePageToken(pToken.Token, parentResourceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	roles, err := r.client.GetRoles(ctx)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to list roles: %w", err)
 	}
 
-	rv := make([]*v2.Resource, 0, len(roles))
-	for _, role := range roles {
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Id < roles[j].Id
+	})
+
+	page, cursor := paginate(roles, func(role xsoar.Role) string { return role.Id }, bag.PageToken(), pageSize(pToken))
+
+	rv := make([]*v2.Resource, 0, len(page))
+	for _, role := range page {
 		roleCopy := role
 
-		rr, err := roleResource(ctx, &roleCopy)
+		rr, err := roleResource(ctx, account, &roleCopy)
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -68,16 +112,30 @@ func (r *roleResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagina
 		rv = append(rv, rr)
 	}
 
-	return rv, "", nil, nil
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(r.client), nil
 }
 
 func (r *roleResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var rv []*v2.Entitlement
 
+	account, _ := parseRoleResourceId(resource.Id.Resource)
+
+	displayName := fmt.Sprintf("%s role", resource.DisplayName)
+	description := fmt.Sprintf("%s Xsoar role", resource.DisplayName)
+	if account != xsoar.MainAccount {
+		displayName = fmt.Sprintf("%s role in %s", resource.DisplayName, accountDisplayName(account))
+		description = fmt.Sprintf("%s Xsoar role in the %s account", resource.DisplayName, accountDisplayName(account))
+	}
+
 	entitlementOptions := []ent.EntitlementOption{
 		ent.WithGrantableTo(resourceTypeUser),
-		ent.WithDisplayName(fmt.Sprintf("%s role", resource.DisplayName)),
-		ent.WithDescription(fmt.Sprintf("%s Xsoar role", resource.DisplayName)),
+		ent.WithDisplayName(displayName),
+		ent.WithDescription(description),
 	}
 
 	rv = append(rv, ent.NewAssignmentEntitlement(resource, roleMember, entitlementOptions...))
@@ -85,49 +143,47 @@ func (r *roleResourceType) Entitlements(_ context.Context, resource *v2.Resource
 	return rv, "", nil, nil
 }
 
-func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	users, err := r.client.GetUsers(ctx)
+func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(pToken.Token, resource.Id)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to get users: %w", err)
+		return nil, "", nil, err
 	}
 
-	var rv []*v2.Grant
-	for _, user := range users {
-		userRoles := flattenRoleNames(user.Roles)
-
-		if !containsRole(userRoles, resource.DisplayName) {
-			continue
-		}
+	account, roleId := parseRoleResourceId(resource.Id.Resource)
 
-		userCopy := user
+	memberIds, err := r.memberships.roleMembers(ctx, account, roleId)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to get role members: %w", err)
+	}
 
-		ur, err := userResource(ctx, &userCopy)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("xsoar-connector: failed to build user resource: %w", err)
-		}
+	page, cursor := paginate(memberIds, func(memberId string) string { return memberId }, bag.PageToken(), pageSize(pToken))
 
+	rv := make([]*v2.Grant, 0, len(page))
+	for _, memberId := range page {
 		rv = append(rv, grant.NewGrant(
 			resource,
 			roleMember,
-			ur.Id,
+			&v2.ResourceId{
+				ResourceType: resourceTypeUser.Id,
+				Resource:     memberId,
+			},
+			grant.WithGrantMetadata(map[string]interface{}{
+				"account": account,
+			}),
 		))
 	}
 
-	return rv, "", nil, nil
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(r.client), nil
 }
 
 func (r *roleResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	if principal.Id.Resource == defaultAdminUser {
-		l.Warn(
-			"xsoar-connector: cannot grant role memberships to default admin user",
-			zap.String("principal_id", principal.Id.Resource),
-		)
-
-		return nil, fmt.Errorf("xsoar-connector: cannot grant role memberships to default admin user")
-	}
-
 	if principal.Id.ResourceType != resourceTypeUser.Id {
 		l.Warn(
 			"xsoar-connector: only users can be granted role membership",
@@ -135,7 +191,7 @@ func (r *roleResourceType) Grant(ctx context.Context, principal *v2.Resource, en
 			zap.String("principal_id", principal.Id.Resource),
 		)
 
-		return nil, fmt.Errorf("xsoar-connector: only users can be granted role membership")
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: only users can be granted role membership")
 	}
 
 	// fetch the current user
@@ -152,45 +208,54 @@ func (r *roleResourceType) Grant(ctx context.Context, principal *v2.Resource, en
 			zap.String("current_user_id", currentUser.Id),
 		)
 
-		return nil, fmt.Errorf("xsoar-connector: cannot grant role membership to current user")
+		return nil, status.Error(codes.PermissionDenied, "xsoar-connector: cannot grant role membership to current user")
 	}
 
-	users, err := r.client.GetUsers(ctx)
+	catalog, err := r.roleCatalog(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("xsoar-connector: failed to get users: %w", err)
+		return nil, err
 	}
 
-	targetUser := findUser(users, principal.Id.Resource)
-	if targetUser == nil {
+	account, roleId := parseRoleResourceId(entitlement.Resource.Id.Resource)
+
+	roleName, err := catalog.roleName(roleId)
+	if err != nil {
 		l.Warn(
-			"xsoar-connector: failed to find user to grant role membership",
+			"xsoar-connector: failed to resolve role to grant",
 			zap.String("principal_id", principal.Id.Resource),
+			zap.String("role_id", entitlement.Resource.Id.Resource),
+			zap.Error(err),
 		)
 
-		return nil, fmt.Errorf("xsoar-connector: failed to find user to grant role membership")
+		return nil, err
 	}
 
-	userRoles := flattenRoleNames(targetUser.Roles)
-	targetRole := entitlement.Resource
+	err = updateUser(ctx, r.client, r.memberships, principal.Id.Resource, func(targetUser *xsoar.User) (*xsoar.UpdateUserBody, error) {
+		if err := r.policy.authorize(ctx, policyActionGrant, targetUser, account, roleId, roleName); err != nil {
+			return nil, err
+		}
 
-	// check if role to be granted is already present
-	if containsRole(userRoles, targetRole.DisplayName) {
-		l.Warn(
-			"xsoar-connector: role membership already granted",
-			zap.String("principal_id", principal.Id.Resource),
-			zap.String("role", targetRole.DisplayName),
-		)
+		// check if role to be granted is already present in the account
+		if containsRole(targetUser.Roles[account], roleName) {
+			l.Warn(
+				"xsoar-connector: role membership already granted",
+				zap.String("principal_id", principal.Id.Resource),
+				zap.String("role", roleName),
+				zap.String("account", account),
+			)
+
+			// TODO: annotate with v2.GrantAlreadyExists once baton-sdk is upgraded to a version providing it.
+			return nil, nil
+		}
 
-		return nil, fmt.Errorf("xsoar-connector: role membership %s already granted", targetRole.DisplayName)
-	}
+		// only the targeted account changes, the roles of every other account are sent back as they are
+		updatedRoles := cloneRoles(targetUser.Roles)
+		updatedRoles[account] = append(updatedRoles[account], roleName)
 
-	err = r.client.UpdateUserRoles(
-		ctx,
-		targetUser.Id,
-		append(userRoles, targetRole.DisplayName),
-	)
+		return &xsoar.UpdateUserBody{Roles: updatedRoles}, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("xsoar-connector: failed to update user roles: %w", err)
+		return nil, err
 	}
 
 	return nil, nil
@@ -202,15 +267,6 @@ func (r *roleResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotat
 	entitlement := grant.Entitlement
 	principal := grant.Principal
 
-	if principal.Id.Resource == defaultAdminUser {
-		l.Warn(
-			"xsoar-connector: cannot revoke role memberships from default admin user",
-			zap.String("principal_id", principal.Id.Resource),
-		)
-
-		return nil, fmt.Errorf("xsoar-connector: cannot revoke role memberships from default admin user")
-	}
-
 	if principal.Id.ResourceType != resourceTypeUser.Id {
 		l.Warn(
 			"xsoar-connector: only users can have role membership revoked",
@@ -232,67 +288,162 @@ func (r *roleResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotat
 			zap.String("current_user_id", currentUser.Id),
 		)
 
-		return nil, fmt.Errorf("xsoar-connector: cannot revoke role membership from current user")
+		return nil, status.Error(codes.PermissionDenied, "xsoar-connector: cannot revoke role membership from current user")
 	}
 
-	users, err := r.client.GetUsers(ctx)
+	catalog, err := r.roleCatalog(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("xsoar-connector: failed to get users: %w", err)
+		return nil, err
 	}
 
-	targetUser := findUser(users, principal.Id.Resource)
-	if targetUser == nil {
+	account, roleId := parseRoleResourceId(entitlement.Resource.Id.Resource)
+
+	roleName, err := catalog.roleName(roleId)
+	if err != nil {
 		l.Warn(
-			"xsoar-connector: failed to find user to revoke role membership",
+			"xsoar-connector: failed to resolve role to revoke",
 			zap.String("principal_id", principal.Id.Resource),
+			zap.String("role_id", entitlement.Resource.Id.Resource),
+			zap.Error(err),
 		)
 
-		return nil, fmt.Errorf("xsoar-connector: failed to find user to revoke role membership")
+		return nil, err
 	}
 
-	userRoles := flattenRoleNames(targetUser.Roles)
-	targetRole := entitlement.Resource
+	err = updateUser(ctx, r.client, r.memberships, principal.Id.Resource, func(targetUser *xsoar.User) (*xsoar.UpdateUserBody, error) {
+		if err := r.policy.authorize(ctx, policyActionRevoke, targetUser, account, roleId, roleName); err != nil {
+			return nil, err
+		}
 
-	// check if role to be revoked is not present
-	if !containsRole(userRoles, targetRole.DisplayName) {
-		l.Warn(
-			"xsoar-connector: role membership already revoked",
-			zap.String("principal_id", principal.Id.Resource),
-			zap.String("role", targetRole.DisplayName),
-		)
+		// check if role to be revoked is not present in the account
+		if !containsRole(targetUser.Roles[account], roleName) {
+			l.Warn(
+				"xsoar-connector: role membership already revoked",
+				zap.String("principal_id", principal.Id.Resource),
+				zap.String("role", roleName),
+				zap.String("account", account),
+			)
+
+			// TODO: annotate with v2.GrantAlreadyRevoked once baton-sdk is upgraded to a version providing it.
+			return nil, nil
+		}
+
+		// remove the role from the targeted account only, the roles of every other account are sent back as they are
+		updatedRoles := cloneRoles(targetUser.Roles)
+		updatedRoles[account] = removeRole(updatedRoles[account], roleName)
 
-		return nil, fmt.Errorf("xsoar-connector: %s role membership already revoked", targetRole.DisplayName)
+		// XSOAR users need at least one role, the last one is handled as configured
+		if countRoles(updatedRoles) == 0 {
+			return r.revokeLastRole(ctx, catalog, targetUser, account, roleName, updatedRoles)
+		}
+
+		return &xsoar.UpdateUserBody{Roles: updatedRoles}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// check if revoked role is not last one existing
-	if len(userRoles) == 1 {
+	return nil, nil
+}
+
+// revokeLastRole builds the update revoking the only role a user has left, according to lastRoleAction. remaining are
+// the user's roles with the role removed, so without any role; the floor role is added to them in the account.
+func (r *roleResourceType) revokeLastRole(
+	ctx context.Context,
+	catalog *roleCatalog,
+	targetUser *xsoar.User,
+	account string,
+	roleName string,
+	remaining map[string][]string,
+) (*xsoar.UpdateUserBody, error) {
+	l := ctxzap.Extract(ctx)
+
+	update := &xsoar.UpdateUserBody{}
+
+	switch r.lastRoleAction {
+	case LastRoleActionFloorRole, LastRoleActionDisable:
+		// without a floor role the revoked role would stay assigned, and the grant with it
+		if r.floorRole == "" {
+			return nil, status.Error(codes.FailedPrecondition, "xsoar-connector: cannot revoke last role membership without a floor role")
+		}
+
+		floorRoleId, floorRoleName, err := catalog.lookup(r.floorRole)
+		if err != nil {
+			return nil, fmt.Errorf("xsoar-connector: failed to resolve floor role: %w", err)
+		}
+
+		if floorRoleName == roleName {
+			l.Warn(
+				"xsoar-connector: cannot revoke floor role as last role membership",
+				zap.String("principal_id", targetUser.Id),
+				zap.String("role", roleName),
+			)
+
+			return nil, status.Error(codes.FailedPrecondition, "xsoar-connector: cannot revoke floor role as last role membership")
+		}
+
+		if err := r.policy.authorize(ctx, policyActionGrant, targetUser, account, floorRoleId, floorRoleName); err != nil {
+			return nil, err
+		}
+
+		l.Info(
+			"xsoar-connector: replacing last role membership with floor role",
+			zap.String("principal_id", targetUser.Id),
+			zap.String("role", roleName),
+			zap.String("floor_role", floorRoleName),
+		)
+
+		update.Roles = remaining
+		update.Roles[account] = []string{floorRoleName}
+
+	default:
 		l.Warn(
 			"xsoar-connector: cannot revoke last role membership",
-			zap.String("principal_id", principal.Id.Resource),
-			zap.String("role", targetRole.DisplayName),
+			zap.String("principal_id", targetUser.Id),
+			zap.String("role", roleName),
 		)
 
-		return nil, fmt.Errorf("xsoar-connector: cannot revoke last role membership")
+		return nil, status.Error(codes.FailedPrecondition, "xsoar-connector: cannot revoke last role membership")
 	}
 
-	// remove the role from the user roles
-	updatedUserRoles := removeRole(userRoles, targetRole.DisplayName)
+	if r.lastRoleAction == LastRoleActionDisable {
+		disabled := true
+		update.Disabled = &disabled
 
-	err = r.client.UpdateUserRoles(
-		ctx,
-		targetUser.Id,
-		updatedUserRoles,
-	)
+		l.Info(
+			"xsoar-connector: disabling user losing last role membership",
+			zap.String("principal_id", targetUser.Id),
+			zap.String("role", roleName),
+		)
+	}
+
+	return update, nil
+}
+
+// roleCatalog fetches the roles to resolve role IDs to their current names, so a role renamed since the last sync
+// still resolves to the right role.
+func (r *roleResourceType) roleCatalog(ctx context.Context) (*roleCatalog, error) {
+	roles, err := r.client.GetRoles(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("xsoar-connector: failed to update user roles: %w", err)
+		return nil, fmt.Errorf("xsoar-connector: failed to get roles: %w", err)
 	}
 
-	return nil, nil
+	return newRoleCatalog(roles), nil
 }
 
-func roleBuilder(client *xsoar.Client) *roleResourceType {
+func roleBuilder(
+	client *xsoar.Client,
+	memberships *membershipIndex,
+	policy *Policy,
+	lastRoleAction LastRoleAction,
+	floorRole string,
+) *roleResourceType {
 	return &roleResourceType{
-		resourceType: resourceTypeRole,
-		client:       client,
+		resourceType:   resourceTypeRole,
+		client:         client,
+		memberships:    memberships,
+		policy:         policy,
+		lastRoleAction: lastRoleAction,
+		floorRole:      floorRole,
 	}
 }
@@ -0,0 +1,237 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// createRole creates a role with a name no other role has and at least one permission.
+func (r *roleResourceType) createRole(ctx context.Context, name string, permissions []string) (*xsoar.Role, error) {
+	l := ctxzap.Extract(ctx)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: a role needs a name")
+	}
+
+	permissions = uniqueRoles(permissions)
+	if len(permissions) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: a role needs at least one permission")
+	}
+
+	catalog, err := r.roleCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(catalog.ids[name]) > 0 {
+		return nil, status.Errorf(codes.AlreadyExists, "xsoar-connector: role %s already exists", name)
+	}
+
+	role, err := r.client.CreateRole(ctx, &xsoar.Role{
+		Name:        name,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to create role: %w", err)
+	}
+
+	r.memberships.invalidate()
+
+	l.Info(
+		"xsoar-connector: created role",
+		zap.String("role_id", role.Id),
+		zap.String("role", role.Name),
+		zap.Strings("permissions", role.Permissions),
+	)
+
+	return role, nil
+}
+
+// deleteRole deletes a role and returns the IDs of the users it was revoked from. Built-in, protected and floor roles
+// are never deleted. A role still held by users is only deleted when forced. The role is then revoked from every one
+// of them with the checks Revoke does, all made before any user is changed, and one update per user. When an update
+// fails the role is kept, and the users it was revoked from until then are returned along with the error.
+func (r *roleResourceType) deleteRole(ctx context.Context, roleId string, force bool) ([]string, error) {
+	l := ctxzap.Extract(ctx)
+
+	catalog, err := r.roleCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roleName, err := catalog.roleName(roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if matchesAny(builtinRoles, roleId, roleName) || matchesAny(r.policy.ProtectedRoles, roleId, roleName) {
+		l.Warn(
+			"xsoar-connector: cannot delete built-in or protected role",
+			zap.String("role_id", roleId),
+			zap.String("role", roleName),
+		)
+
+		return nil, status.Errorf(codes.PermissionDenied, "xsoar-connector: cannot delete built-in or protected role %s", roleName)
+	}
+
+	if r.floorRole != "" && matchesAny([]string{r.floorRole}, roleId, roleName) {
+		return nil, status.Errorf(codes.FailedPrecondition, "xsoar-connector: cannot delete floor role %s", roleName)
+	}
+
+	// read the members from fresh data, the index may predate changes made since the sync
+	var members []xsoar.User
+	err = r.client.StreamUsers(ctx, func(user xsoar.User) error {
+		for _, roles := range user.Roles {
+			if containsRole(roles, roleName) {
+				members = append(members, user)
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get users: %w", err)
+	}
+
+	if len(members) > 0 && !force {
+		l.Warn(
+			"xsoar-connector: cannot delete role with members",
+			zap.String("role_id", roleId),
+			zap.Int("members", len(members)),
+		)
+
+		return nil, status.Errorf(codes.FailedPrecondition, "xsoar-connector: role %s still has %d members", roleName, len(members))
+	}
+
+	updates, err := r.planRoleRevocations(ctx, catalog, roleId, roleName, members)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: cannot revoke role %s from its members, nothing was changed: %w", roleName, err)
+	}
+
+	var revoked []string
+	for _, update := range updates {
+		err := r.client.UpdateUser(ctx, update)
+		if err != nil {
+			r.memberships.invalidate()
+
+			return revoked, fmt.Errorf(
+				"xsoar-connector: failed to revoke role %s from user %s, the role was kept after being revoked from %d users: %w",
+				roleName,
+				update.Id,
+				len(revoked),
+				err,
+			)
+		}
+
+		revoked = append(revoked, update.Id)
+	}
+
+	err = r.client.DeleteRole(ctx, roleId)
+	r.memberships.invalidate()
+	if err != nil {
+		return revoked, fmt.Errorf("xsoar-connector: failed to delete role: %w", err)
+	}
+
+	l.Info(
+		"xsoar-connector: deleted role",
+		zap.String("role_id", roleId),
+		zap.String("role", roleName),
+		zap.Bool("forced", force),
+		zap.Int("revoked", len(revoked)),
+	)
+
+	return revoked, nil
+}
+
+// planRoleRevocations checks revoking the role from each member, in every account they hold it in, like Revoke does,
+// and builds one update per member. Nothing is sent, so when a check fails no member has lost the role.
+func (r *roleResourceType) planRoleRevocations(
+	ctx context.Context,
+	catalog *roleCatalog,
+	roleId string,
+	roleName string,
+	members []xsoar.User,
+) ([]*xsoar.UpdateUserBody, error) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	currentUser, err := r.client.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get current user: %w", err)
+	}
+
+	updates := make([]*xsoar.UpdateUserBody, 0, len(members))
+	for i := range members {
+		member := &members[i]
+
+		if member.Id == currentUser.Id {
+			return nil, status.Error(codes.PermissionDenied, "xsoar-connector: cannot revoke role membership from current user")
+		}
+
+		var accounts []string
+		for account, roles := range member.Roles {
+			if containsRole(roles, roleName) {
+				accounts = append(accounts, account)
+			}
+		}
+		sort.Strings(accounts)
+
+		updatedRoles := cloneRoles(member.Roles)
+		for _, account := range accounts {
+			if err := r.policy.authorize(ctx, policyActionRevoke, member, account, roleId, roleName); err != nil {
+				return nil, err
+			}
+
+			updatedRoles[account] = removeRole(updatedRoles[account], roleName)
+		}
+
+		update := &xsoar.UpdateUserBody{Roles: updatedRoles}
+		if countRoles(updatedRoles) == 0 {
+			update, err = r.revokeLastRole(ctx, catalog, member, accounts[0], roleName, updatedRoles)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		update.Id = member.Id
+		update.Version = member.Version
+
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
+// CreateRole creates a role with the given name and permissions.
+func (xs *Xsoar) CreateRole(ctx context.Context, name string, permissions []string) (*xsoar.Role, error) {
+	return xs.roleBuilder().createRole(ctx, name, permissions)
+}
+
+// DeleteRole deletes the role with the given ID or name and returns the IDs of the users it was revoked from. A role
+// still held by users is only deleted when forced, see roleResourceType.deleteRole.
+func (xs *Xsoar) DeleteRole(ctx context.Context, idOrName string, force bool) ([]string, error) {
+	r := xs.roleBuilder()
+
+	catalog, err := r.roleCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roleId, _, err := catalog.lookup(idOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deleteRole(ctx, roleId, force)
+}
@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testAccountA = "acc_tenant-a"
+	testAccountB = "acc_tenant-b"
+)
+
+var testRoles = []xsoar.Role{
+	{BaseResource: xsoar.BaseResource{Id: "role-analyst"}, Name: "Analyst", Permissions: []string{"incidents.read"}},
+	{BaseResource: xsoar.BaseResource{Id: "role-read-only"}, Name: "Read-Only", Permissions: []string{"incidents.read"}},
+	{BaseResource: xsoar.BaseResource{Id: "role-admin"}, Name: "Administrator", Permissions: []string{"admin"}},
+	{BaseResource: xsoar.BaseResource{Id: "role-soc"}, Name: "SOC Tier 1", Permissions: []string{"incidents.edit"}},
+}
+
+// newTestServer serves the current user, the roles and user-1 holding the given roles, and records the body of every
+// user update.
+func newTestServer(t *testing.T, roles map[string][]string, updates *[]xsoar.UpdateUserBody) *httptest.Server {
+	t.Helper()
+
+	users := []xsoar.User{
+		{BaseResource: xsoar.BaseResource{Id: "connector"}, Username: "connector"},
+		{
+			BaseResource: xsoar.BaseResource{Id: "user-1", Version: 7},
+			Username:     "jdoe",
+			Roles:        roles,
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(xsoar.CurrentUserEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(users[0])
+	})
+	mux.HandleFunc(xsoar.UsersEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(users)
+	})
+	mux.HandleFunc(xsoar.RolesEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(testRoles)
+	})
+	mux.HandleFunc(xsoar.UpdateUserEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		var update xsoar.UpdateUserBody
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		*updates = append(*updates, update)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// multiAccountRoles has user-1 hold roles in the main account and two child accounts.
+func multiAccountRoles() map[string][]string {
+	return map[string][]string{
+		xsoar.MainAccount: {"Analyst"},
+		testAccountA:      {"Read-Only", "Analyst"},
+		testAccountB:      {"Administrator"},
+	}
+}
+
+func newTestRoleResourceType(server *httptest.Server) *roleResourceType {
+	client := xsoar.NewClient(server.Client(), "token", server.URL, xsoar.WithApiVersion(xsoar.ApiVersion6))
+
+	return roleBuilder(client, newMembershipIndex(client), &Policy{}, LastRoleActionRefuse, "")
+}
+
+func testRoleEntitlement(t *testing.T, account string, role *xsoar.Role) *v2.Entitlement {
+	t.Helper()
+
+	resource, err := roleResource(context.Background(), account, role)
+	if err != nil {
+		t.Fatalf("failed to create role resource: %v", err)
+	}
+
+	return &v2.Entitlement{Resource: resource}
+}
+
+func testUserPrincipal() *v2.Resource {
+	return &v2.Resource{
+		Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "user-1"},
+	}
+}
+
+func TestRoleGrantLeavesOtherAccountsAlone(t *testing.T) {
+	var updates []xsoar.UpdateUserBody
+	r := newTestRoleResourceType(newTestServer(t, multiAccountRoles(), &updates))
+
+	_, err := r.Grant(context.Background(), testUserPrincipal(), testRoleEntitlement(t, xsoar.MainAccount, &testRoles[1]))
+	if err != nil {
+		t.Fatalf("grant failed: %v", err)
+	}
+
+	if len(updates) != 1 {
+		t.Fatalf("expected 1 user update, got %d", len(updates))
+	}
+
+	want := map[string][]string{
+		xsoar.MainAccount: {"Analyst", "Read-Only"},
+		testAccountA:      {"Read-Only", "Analyst"},
+		testAccountB:      {"Administrator"},
+	}
+	if !reflect.DeepEqual(updates[0].Roles, want) {
+		t.Errorf("unexpected roles in update: got %v, want %v", updates[0].Roles, want)
+	}
+
+	if updates[0].Version != 7 {
+		t.Errorf("expected the update to carry version 7, got %d", updates[0].Version)
+	}
+}
+
+func TestRoleRevokeLeavesOtherAccountsAlone(t *testing.T) {
+	var updates []xsoar.UpdateUserBody
+	r := newTestRoleResourceType(newTestServer(t, multiAccountRoles(), &updates))
+
+	_, err := r.Revoke(context.Background(), &v2.Grant{
+		Entitlement: testRoleEntitlement(t, testAccountA, &testRoles[0]),
+		Principal:   testUserPrincipal(),
+	})
+	if err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	if len(updates) != 1 {
+		t.Fatalf("expected 1 user update, got %d", len(updates))
+	}
+
+	want := map[string][]string{
+		xsoar.MainAccount: {"Analyst"},
+		testAccountA:      {"Read-Only"},
+		testAccountB:      {"Administrator"},
+	}
+	if !reflect.DeepEqual(updates[0].Roles, want) {
+		t.Errorf("unexpected roles in update: got %v, want %v", updates[0].Roles, want)
+	}
+}
+
+func TestRoleRevokeOfDuplicatedLastRoleIsRefused(t *testing.T) {
+	var updates []xsoar.UpdateUserBody
+	server := newTestServer(t, map[string][]string{xsoar.MainAccount: {"Analyst", "Analyst"}}, &updates)
+	r := newTestRoleResourceType(server)
+
+	_, err := r.Revoke(context.Background(), &v2.Grant{
+		Entitlement: testRoleEntitlement(t, xsoar.MainAccount, &testRoles[0]),
+		Principal:   testUserPrincipal(),
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected the revoke of the last role to be refused, got %v", err)
+	}
+
+	if len(updates) != 0 {
+		t.Errorf("expected no user update, got %v", updates)
+	}
+}
+
+func TestForcedRoleDeletionChecksEveryMemberFirst(t *testing.T) {
+	var updates []xsoar.UpdateUserBody
+	server := newTestServer(t, map[string][]string{xsoar.MainAccount: {"SOC Tier 1"}}, &updates)
+	r := newTestRoleResourceType(server)
+
+	revoked, err := r.deleteRole(context.Background(), "role-soc", true)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected the deletion to be refused for the member's last role, got %v", err)
+	}
+
+	if len(revoked) != 0 || len(updates) != 0 {
+		t.Errorf("expected no user to be changed, got revoked %v and updates %v", revoked, updates)
+	}
+}
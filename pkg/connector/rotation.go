@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TokenRotation describes the API key which replaced the connector's own.
+type TokenRotation struct {
+	OldKeyId   string
+	NewKeyId   string
+	NewKeyName string
+}
+
+// RotateToken replaces the API key the connector uses. It creates a new key for the same user, checks that the new
+// key authenticates as that user, hands the secret to writeSecret and only then deletes the old key. When a step
+// before writeSecret fails, the new key is deleted again and the old key is left as it is.
+//
+// The old key is identified by the configured API key ID, which is required.
+func (xs *Xsoar) RotateToken(ctx context.Context, name string, writeSecret func(ctx context.Context, secret string) error) (*TokenRotation, error) {
+	l := ctxzap.Extract(ctx)
+
+	oldKeyId := xs.client.ApiKeyId
+	if oldKeyId == "" {
+		return nil, status.Error(codes.FailedPrecondition, "xsoar-connector: the api key id of the current token is required to rotate it")
+	}
+
+	currentUser, err := xs.client.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get current user: %w", err)
+	}
+
+	// make sure the key to delete is the one in use before creating anything
+	keys, err := xs.client.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get api keys: %w", err)
+	}
+
+	var oldKey *xsoar.APIKey
+	for i := range keys {
+		if keys[i].Id == oldKeyId {
+			oldKey = &keys[i]
+			break
+		}
+	}
+
+	if oldKey == nil {
+		return nil, status.Errorf(codes.NotFound, "xsoar-connector: api key %s of the current token not found", oldKeyId)
+	}
+
+	if oldKey.Username != "" && oldKey.Username != currentUser.Username {
+		return nil, status.Errorf(codes.FailedPrecondition, "xsoar-connector: api key %s belongs to %s, not to the current user", oldKeyId, oldKey.Username)
+	}
+
+	newKey, secret, err := xs.client.CreateAPIKey(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to create api key: %w", err)
+	}
+
+	l.Info(
+		"xsoar-connector: created api key",
+		zap.String("api_key_id", newKey.Id),
+		zap.String("api_key_name", newKey.Name),
+	)
+
+	rotation := &TokenRotation{
+		OldKeyId:   oldKeyId,
+		NewKeyId:   newKey.Id,
+		NewKeyName: newKey.Name,
+	}
+
+	newClient, err := xs.newClient(secret, newKey.Id)
+	if err != nil {
+		return nil, xs.discardAPIKey(ctx, newKey.Id, fmt.Errorf("xsoar-connector: failed to create client for new api key: %w", err))
+	}
+
+	newUser, err := newClient.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, xs.discardAPIKey(ctx, newKey.Id, fmt.Errorf("xsoar-connector: new api key failed validation: %w", err))
+	}
+
+	if newUser.Id != currentUser.Id {
+		return nil, xs.discardAPIKey(ctx, newKey.Id, status.Errorf(
+			codes.FailedPrecondition,
+			"xsoar-connector: new api key authenticates as %s instead of %s",
+			newUser.Id,
+			currentUser.Id,
+		))
+	}
+
+	err = writeSecret(ctx, secret)
+	if err != nil {
+		return nil, xs.discardAPIKey(ctx, newKey.Id, fmt.Errorf("xsoar-connector: failed to write new api key: %w", err))
+	}
+
+	// the new key is in place from here on, failures leave both keys valid
+	err = newClient.DeleteAPIKey(ctx, oldKeyId)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return rotation, fmt.Errorf("xsoar-connector: new api key %s is in place but deleting old api key %s failed: %w", newKey.Id, oldKeyId, err)
+	}
+
+	l.Info(
+		"xsoar-connector: rotated api key",
+		zap.String("old_api_key_id", oldKeyId),
+		zap.String("api_key_id", newKey.Id),
+	)
+
+	return rotation, nil
+}
+
+// discardAPIKey deletes a key created by an aborted rotation and returns the error which aborted it.
+func (xs *Xsoar) discardAPIKey(ctx context.Context, keyId string, cause error) error {
+	l := ctxzap.Extract(ctx)
+
+	err := xs.client.DeleteAPIKey(ctx, keyId)
+	if err != nil {
+		l.Error(
+			"xsoar-connector: failed to delete api key of aborted rotation, delete it manually",
+			zap.String("api_key_id", keyId),
+			zap.Error(err),
+		)
+	}
+
+	return cause
+}
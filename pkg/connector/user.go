@@ -3,17 +3,27 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type userResourceType struct {
 	resourceType *v2.ResourceType
 	client       *xsoar.Client
+	memberships  *membershipIndex
+	policy       *Policy
+	floorRole    string
+
+	passwordPolicy *PasswordPolicy
 }
 
 func (u *userResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -54,17 +64,27 @@ func userResource(ctx context.Context, user *xsoar.User) (*v2.Resource, error) {
 	return ret, nil
 }
 
-func (u *userResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	users, err := u.client.GetUsers(ctx)
+func (u *userResourceType) List(ctx context.Context, _ *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// users are listed before any grants, so a fresh listing marks the start of a sync
+	if bag.PageToken() == "" {
+		u.memberships.invalidate()
+	}
+
+	users, err := u.memberships.allUsers(ctx)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("xsoar-connector: failed to list users: %w", err)
 	}
 
-	rv := make([]*v2.Resource, 0, len(users))
-	for _, user := range users {
-		userCopy := user
+	page, cursor := paginate(users, func(user userSummary) string { return user.Id }, bag.PageToken(), pageSize(pToken))
 
-		ur, err := userResource(ctx, &userCopy)
+	rv := make([]*v2.Resource, 0, len(page))
+	for _, user := range page {
+		ur, err := userResource(ctx, user.user())
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -72,7 +92,12 @@ func (u *userResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagina
 		rv = append(rv, ur)
 	}
 
-	return rv, "", nil, nil
+	nextPage, err := bag.NextToken(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, nextPage, rateLimitAnnotations(u.client), nil
 }
 
 func (u *userResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
@@ -83,9 +108,201 @@ func (u *userResourceType) Grants(_ context.Context, _ *v2.Resource, _ *paginati
 	return nil, "", nil, nil
 }
 
-func userBuilder(client *xsoar.Client) *userResourceType {
+// setDisabled disables or enables a user, keeping their roles and investigation history. The default admin user, the
+// connector's own user and protected users are never changed. The user is read again afterwards to confirm the
+// change.
+func (u *userResourceType) setDisabled(ctx context.Context, userId string, disabled bool) (*v2.Resource, error) {
+	l := ctxzap.Extract(ctx)
+
+	action := userActionEnable
+	if disabled {
+		action = userActionDisable
+	}
+
+	err := updateUser(ctx, u.client, u.memberships, userId, func(targetUser *xsoar.User) (*xsoar.UpdateUserBody, error) {
+		if err := u.authorizeUserChange(ctx, action, targetUser); err != nil {
+			return nil, err
+		}
+
+		if targetUser.Disabled == disabled {
+			l.Warn(
+				"xsoar-connector: user already has requested status",
+				zap.String("principal_id", targetUser.Id),
+				zap.String("action", string(action)),
+			)
+
+			return nil, nil
+		}
+
+		return &xsoar.UpdateUserBody{Disabled: &disabled}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updatedUser, err := u.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get user: %w", err)
+	}
+
+	if updatedUser == nil {
+		return nil, status.Errorf(codes.NotFound, "xsoar-connector: user %s disappeared after %s", userId, action)
+	}
+
+	if updatedUser.Disabled != disabled {
+		return nil, status.Errorf(codes.Internal, "xsoar-connector: user %s was not changed by %s", userId, action)
+	}
+
+	l.Info(
+		"xsoar-connector: changed user status",
+		zap.String("principal_id", userId),
+		zap.String("action", string(action)),
+	)
+
+	return userResource(ctx, updatedUser)
+}
+
+// DisableUser disables the user with the given ID or username.
+func (xs *Xsoar) DisableUser(ctx context.Context, idOrUsername string) (*v2.Resource, error) {
+	return xs.setUserDisabled(ctx, idOrUsername, true)
+}
+
+// EnableUser enables the user with the given ID or username.
+func (xs *Xsoar) EnableUser(ctx context.Context, idOrUsername string) (*v2.Resource, error) {
+	return xs.setUserDisabled(ctx, idOrUsername, false)
+}
+
+func (xs *Xsoar) setUserDisabled(ctx context.Context, idOrUsername string, disabled bool) (*v2.Resource, error) {
+	user, err := findUser(ctx, xs.client, idOrUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	return xs.userBuilder().setDisabled(ctx, user.Id, disabled)
+}
+
+// authorizeUserChange fetches the connector's own user and checks the change to the target user against the policy,
+// see Policy.authorizeUserChange.
+func (u *userResourceType) authorizeUserChange(ctx context.Context, action userAction, targetUser *xsoar.User) error {
+	currentUser, err := u.client.GetCurrentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("xsoar-connector: failed to get current user: %w", err)
+	}
+
+	return u.policy.authorizeUserChange(ctx, action, currentUser.Id, targetUser)
+}
+
+// findUser returns the user with the given ID, or else with the given username ignoring case.
+func findUser(ctx context.Context, client *xsoar.Client, idOrUsername string) (*xsoar.User, error) {
+	var byUsername *xsoar.User
+
+	var found *xsoar.User
+	err := client.StreamUsers(ctx, func(user xsoar.User) error {
+		if user.Id == idOrUsername {
+			found = &user
+			return nil
+		}
+
+		if byUsername == nil && strings.EqualFold(user.Username, idOrUsername) {
+			byUsername = &user
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get users: %w", err)
+	}
+
+	if found == nil {
+		found = byUsername
+	}
+
+	if found == nil {
+		return nil, status.Errorf(codes.NotFound, "xsoar-connector: user %s not found", idOrUsername)
+	}
+
+	return found, nil
+}
+
+// updateUser reads the user, builds an update with change and sends it along with the version it read. When
+// change returns no update, the user already is in the wanted state and nothing is sent.
+// When the user was updated by someone else in between, XSOAR rejects the update; the user is then read again and
+// change applied to the new version, up to maxRoleUpdateAttempts times.
+func updateUser(
+	ctx context.Context,
+	client *xsoar.Client,
+	memberships *membershipIndex,
+	userId string,
+	change func(targetUser *xsoar.User) (*xsoar.UpdateUserBody, error),
+) error {
+	l := ctxzap.Extract(ctx)
+
+	for attempt := 1; attempt <= maxRoleUpdateAttempts; attempt++ {
+		targetUser, err := client.GetUser(ctx, userId)
+		if err != nil {
+			return fmt.Errorf("xsoar-connector: failed to get user: %w", err)
+		}
+
+		if targetUser == nil {
+			l.Warn(
+				"xsoar-connector: failed to find user to update",
+				zap.String("principal_id", userId),
+			)
+
+			return status.Error(codes.NotFound, "xsoar-connector: failed to find user to update")
+		}
+
+		update, err := change(targetUser)
+		if err != nil {
+			return err
+		}
+
+		if update == nil {
+			return nil
+		}
+
+		update.Id = targetUser.Id
+		update.Version = targetUser.Version
+
+		err = client.UpdateUser(ctx, update)
+		if err == nil {
+			memberships.invalidate()
+			return nil
+		}
+
+		if !xsoar.IsVersionConflict(err) {
+			return fmt.Errorf("xsoar-connector: failed to update user: %w", err)
+		}
+
+		l.Warn(
+			"xsoar-connector: user was modified concurrently, retrying update",
+			zap.String("principal_id", userId),
+			zap.Int("version", targetUser.Version),
+			zap.Int("attempt", attempt),
+		)
+	}
+
+	return status.Errorf(
+		codes.Aborted,
+		"xsoar-connector: user %s kept being modified concurrently, update gave up after %d attempts",
+		userId,
+		maxRoleUpdateAttempts,
+	)
+}
+
+func userBuilder(
+	client *xsoar.Client,
+	memberships *membershipIndex,
+	policy *Policy,
+	floorRole string,
+	passwordPolicy *PasswordPolicy,
+) *userResourceType {
 	return &userResourceType{
-		resourceType: resourceTypeUser,
-		client:       client,
+		resourceType:   resourceTypeUser,
+		client:         client,
+		memberships:    memberships,
+		policy:         policy,
+		floorRole:      floorRole,
+		passwordPolicy: passwordPolicy,
 	}
 }
@@ -0,0 +1,159 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeletionSummary describes a deleted user and where their incidents and tasks went, or for a dry run where they
+// would go.
+type DeletionSummary struct {
+	UserId   string `json:"userId"`
+	Username string `json:"username"`
+	// Successor is the username of the user the incidents and tasks were reassigned to.
+	Successor   string       `json:"successor"`
+	IncidentIds []string     `json:"incidentIds"`
+	Tasks       []xsoar.Task `json:"tasks"`
+	DryRun      bool         `json:"dryRun"`
+}
+
+// deleteUser reassigns the incidents owned by the user and the playbook tasks assigned to them to the successor, given
+// by ID or username, or else to the user's manager, and then deletes the user. The default admin user, the
+// connector's own user and protected users are never deleted. When a reassignment fails the user is kept, and
+// reassignments done so far stay: they are returned in the summary along with the error. A dry run only returns the
+// plan, changing nothing.
+func (u *userResourceType) deleteUser(ctx context.Context, userId string, successor string, dryRun bool) (*DeletionSummary, error) {
+	l := ctxzap.Extract(ctx)
+
+	targetUser, err := u.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get user: %w", err)
+	}
+
+	if targetUser == nil {
+		return nil, status.Errorf(codes.NotFound, "xsoar-connector: user %s not found", userId)
+	}
+
+	if err := u.authorizeUserChange(ctx, userActionDelete, targetUser); err != nil {
+		return nil, err
+	}
+
+	if successor == "" {
+		successor = targetUser.Manager
+	}
+
+	if successor == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "xsoar-connector: user %s has no manager, a successor is required", targetUser.Id)
+	}
+
+	successorUser, err := findUser(ctx, u.client, successor)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to find successor: %w", err)
+	}
+
+	if successorUser.Id == targetUser.Id || successorUser.Disabled {
+		return nil, status.Errorf(codes.FailedPrecondition, "xsoar-connector: successor %s must be another, enabled user", successorUser.Username)
+	}
+
+	incidents, err := u.client.GetIncidentsOwnedBy(ctx, targetUser.Username)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to search incidents: %w", err)
+	}
+
+	tasks, err := u.client.GetTasksAssignedTo(ctx, targetUser.Username)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to search tasks: %w", err)
+	}
+
+	incidentIds := make([]string, 0, len(incidents))
+	for _, incident := range incidents {
+		incidentIds = append(incidentIds, incident.Id)
+	}
+
+	l.Info(
+		"xsoar-connector: planned user deletion",
+		zap.String("principal_id", targetUser.Id),
+		zap.String("username", targetUser.Username),
+		zap.String("successor", successorUser.Username),
+		zap.Int("incidents", len(incidentIds)),
+		zap.Int("tasks", len(tasks)),
+		zap.Bool("dry_run", dryRun),
+	)
+
+	if dryRun {
+		return &DeletionSummary{
+			UserId:      targetUser.Id,
+			Username:    targetUser.Username,
+			Successor:   successorUser.Username,
+			IncidentIds: incidentIds,
+			Tasks:       tasks,
+			DryRun:      true,
+		}, nil
+	}
+
+	summary := &DeletionSummary{
+		UserId:    targetUser.Id,
+		Username:  targetUser.Username,
+		Successor: successorUser.Username,
+	}
+
+	for start := 0; start < len(incidentIds); start += xsoar.SearchPageSize {
+		end := start + xsoar.SearchPageSize
+		if end > len(incidentIds) {
+			end = len(incidentIds)
+		}
+
+		err = u.client.SetIncidentsOwner(ctx, incidentIds[start:end], successorUser.Username)
+		if err != nil {
+			return summary, fmt.Errorf("xsoar-connector: failed to reassign incidents, user was not deleted: %w", err)
+		}
+
+		summary.IncidentIds = append(summary.IncidentIds, incidentIds[start:end]...)
+	}
+
+	for i := range tasks {
+		err = u.client.AssignTask(ctx, &tasks[i], successorUser.Username)
+		if err != nil {
+			return summary, fmt.Errorf("xsoar-connector: failed to reassign task %s of incident %s, user was not deleted: %w", tasks[i].Id, tasks[i].IncidentId, err)
+		}
+
+		summary.Tasks = append(summary.Tasks, tasks[i])
+	}
+
+	err = u.client.DeleteUser(ctx, targetUser.Id)
+	if err != nil {
+		return summary, fmt.Errorf("xsoar-connector: failed to delete user: %w", err)
+	}
+
+	u.memberships.invalidate()
+
+	l.Info(
+		"xsoar-connector: deleted user",
+		zap.String("principal_id", targetUser.Id),
+		zap.String("username", targetUser.Username),
+		zap.String("successor", successorUser.Username),
+		zap.Int("incidents", len(summary.IncidentIds)),
+		zap.Int("tasks", len(summary.Tasks)),
+	)
+
+	return summary, nil
+}
+
+// DeleteUser deletes the user with the given ID or username after handing their incidents and tasks over to the
+// successor, or only plans it for a dry run, see userResourceType.deleteUser. The summary may come with an error, and
+// then lists the reassignments done before the failure.
+func (xs *Xsoar) DeleteUser(ctx context.Context, idOrUsername string, successor string, dryRun bool) (*DeletionSummary, error) {
+	user, err := findUser(ctx, xs.client, strings.TrimSpace(idOrUsername))
+	if err != nil {
+		return nil, err
+	}
+
+	return xs.userBuilder().deleteUser(ctx, user.Id, successor, dryRun)
+}
@@ -0,0 +1,232 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-xsoar/pkg/xsoar"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The SDK this connector is built on has no account provisioning call, nor a channel returning credentials, so
+// users are created through Xsoar.CreateAccount and passwords reset through Xsoar.ResetPassword, and the caller hands
+// the passwords over.
+
+// AccountRequest describes a user to create.
+type AccountRequest struct {
+	Email    string
+	Name     string
+	Username string
+	// Roles are the IDs or names of the roles of the user in the main account. Without roles, the user gets the
+	// floor role.
+	Roles []string
+	// Invite sends an invitation instead of creating a local user with a random initial password.
+	Invite bool
+}
+
+// AccountResult describes a created user or a sent invitation.
+type AccountResult struct {
+	// Resource is the created user. It is nil for invitations, as the user only exists once they accept.
+	Resource *v2.Resource
+	InviteId string
+	// Password is the initial password of a local user.
+	Password string
+}
+
+// createAccount creates a local user with a random initial password, or invites the user. Cortex XSOAR 8 has no
+// local users, so users are always invited there. The roles are checked against the policy like role grants.
+func (u *userResourceType) createAccount(ctx context.Context, req *AccountRequest) (*AccountResult, error) {
+	l := ctxzap.Extract(ctx)
+
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: an email is required to create a user")
+	}
+
+	if !req.Invite && req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: a username is required to create a local user")
+	}
+
+	apiVersion, err := u.client.ApiVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to detect api version: %w", err)
+	}
+
+	if apiVersion == xsoar.ApiVersion8 && !req.Invite {
+		return nil, status.Error(codes.FailedPrecondition, "xsoar-connector: Cortex XSOAR 8 has no local users, users must be invited")
+	}
+
+	roleNames, err := u.resolveInitialRoles(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := map[string][]string{
+		xsoar.MainAccount: roleNames,
+	}
+
+	if req.Invite {
+		invite, err := u.client.InviteUser(ctx, &xsoar.InviteBody{
+			Email: req.Email,
+			Roles: roles,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("xsoar-connector: failed to invite user: %w", err)
+		}
+
+		l.Info(
+			"xsoar-connector: invited user",
+			zap.String("invite_id", invite.Id),
+			zap.String("email", req.Email),
+			zap.Strings("roles", roleNames),
+		)
+
+		return &AccountResult{InviteId: invite.Id}, nil
+	}
+
+	password, err := u.passwordPolicy.generate()
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to generate password: %w", err)
+	}
+
+	user, err := u.client.CreateUser(ctx, &xsoar.CreateUserBody{
+		Username: req.Username,
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: password,
+		Roles:    roles,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to create user: %w", err)
+	}
+
+	u.memberships.invalidate()
+
+	l.Info(
+		"xsoar-connector: created user",
+		zap.String("principal_id", user.Id),
+		zap.String("username", user.Username),
+		zap.Strings("roles", roleNames),
+	)
+
+	ur, err := userResource(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountResult{
+		Resource: ur,
+		Password: password,
+	}, nil
+}
+
+// resolveInitialRoles resolves the requested roles, or the floor role, to names and checks each against the policy.
+func (u *userResourceType) resolveInitialRoles(ctx context.Context, req *AccountRequest) ([]string, error) {
+	requested := req.Roles
+	if len(requested) == 0 && u.floorRole != "" {
+		requested = []string{u.floorRole}
+	}
+
+	if len(requested) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "xsoar-connector: at least one role is required to create a user")
+	}
+
+	roles, err := u.client.GetRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("xsoar-connector: failed to get roles: %w", err)
+	}
+	catalog := newRoleCatalog(roles)
+
+	// the user does not exist yet, the policy only knows their username
+	newUser := &xsoar.User{Username: req.Username}
+	if newUser.Username == "" {
+		newUser.Username = req.Email
+	}
+
+	var roleNames []string
+	for _, role := range requested {
+		roleId, roleName, err := catalog.lookup(strings.TrimSpace(role))
+		if err != nil {
+			return nil, err
+		}
+
+		err = u.policy.authorize(ctx, policyActionGrant, newUser, xsoar.MainAccount, roleId, roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		roleNames = append(roleNames, roleName)
+	}
+
+	return uniqueRoles(roleNames), nil
+}
+
+// CreateAccount creates a local user or invites a user, see AccountRequest.
+func (xs *Xsoar) CreateAccount(ctx context.Context, req *AccountRequest) (*AccountResult, error) {
+	return xs.userBuilder().createAccount(ctx, req)
+}
+
+// rotateCredential sets a new random password, following the password policy, for a local user and returns it. Users
+// signing in through SSO or LDAP, or not reporting how they sign in, are refused, as are all users of Cortex XSOAR 8. The
+// default admin user, the connector's own user and protected users are never changed.
+func (u *userResourceType) rotateCredential(ctx context.Context, userId string) (string, error) {
+	l := ctxzap.Extract(ctx)
+
+	apiVersion, err := u.client.ApiVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("xsoar-connector: failed to detect api version: %w", err)
+	}
+
+	if apiVersion == xsoar.ApiVersion8 {
+		return "", status.Error(codes.FailedPrecondition, "xsoar-connector: Cortex XSOAR 8 has no local users, passwords are managed by the identity provider")
+	}
+
+	password, err := u.passwordPolicy.generate()
+	if err != nil {
+		return "", fmt.Errorf("xsoar-connector: failed to generate password: %w", err)
+	}
+
+	err = updateUser(ctx, u.client, u.memberships, userId, func(targetUser *xsoar.User) (*xsoar.UpdateUserBody, error) {
+		if err := u.authorizeUserChange(ctx, userActionResetPassword, targetUser); err != nil {
+			return nil, err
+		}
+
+		if !targetUser.IsLocal() {
+			l.Warn(
+				"xsoar-connector: skipping password reset of external user",
+				zap.String("principal_id", targetUser.Id),
+				zap.String("auth_type", targetUser.AuthType),
+			)
+
+			return nil, status.Errorf(codes.FailedPrecondition, "xsoar-connector: user %s is not a local user and has no password to reset", targetUser.Id)
+		}
+
+		return &xsoar.UpdateUserBody{Password: password}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the password is never logged
+	l.Info(
+		"xsoar-connector: reset user password",
+		zap.String("principal_id", userId),
+	)
+
+	return password, nil
+}
+
+// ResetPassword sets a new random password for the local user with the given ID or username and returns it, see
+// userResourceType.rotateCredential.
+func (xs *Xsoar) ResetPassword(ctx context.Context, idOrUsername string) (string, error) {
+	user, err := findUser(ctx, xs.client, strings.TrimSpace(idOrUsername))
+	if err != nil {
+		return "", err
+	}
+
+	return xs.userBuilder().rotateCredential(ctx, user.Id)
+}
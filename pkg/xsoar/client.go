@@ -4,71 +4,266 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
 
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+const (
+	CurrentUserEndpoint = "/user"
+	UsersEndpoint       = "/users"
+	RolesEndpoint       = "/roles"
+	UpdateUserEndpoint  = "/users/update"
+	UpdateRoleEndpoint  = "/roles/update"
+	APIKeysEndpoint     = "/apikeys"
+	CreateUserEndpoint  = "/users/create"
+	InvitesEndpoint     = "/invites"
 )
 
+// ApiVersion selects the flavour of the Cortex XSOAR API the client talks to.
+type ApiVersion string
+
 const (
-	ApiBaseURL         = "%s"
-	CurrentUserBaseURL = ApiBaseURL + "/user"
-	UsersBaseURL       = ApiBaseURL + "/users"
-	RolesBaseURL       = ApiBaseURL + "/roles"
-	UpdateUserBaseURL  = ApiBaseURL + "/users/update"
+	// ApiVersionAuto detects the API version from the configuration and the server.
+	ApiVersionAuto ApiVersion = "auto"
+	// ApiVersion6 targets Cortex XSOAR 6, authenticated with the API key only.
+	ApiVersion6 ApiVersion = "6"
+	// ApiVersion8 targets Cortex XSOAR 8 and XSIAM tenants, authenticated with the API key and its ID.
+	ApiVersion8 ApiVersion = "8"
 )
 
+// v8PathPrefixes lists the path prefixes XSOAR 8 and XSIAM tenants serve the API under, in order of preference.
+var v8PathPrefixes = []string{"/xsoar/public/v1", "/xsoar"}
+
 type Client struct {
 	httpClient *http.Client
 	Token      string
+	ApiKeyId   string
 	ApiUrl     string
+
+	apiVersion  ApiVersion
+	signer      Signer
+	retryPolicy RetryPolicy
+	limiter     *rateLimiter
+
+	rateLimitMu    sync.Mutex
+	throttled      bool
+	throttledUntil time.Time
+
+	mu         sync.Mutex
+	resolved   bool
+	pathPrefix string
+}
+
+type Option func(*Client)
+
+// WithApiKeyId sets the ID of the API key, sent in the `x-xdr-auth-id` header to XSOAR 8 and XSIAM tenants.
+func WithApiKeyId(apiKeyId string) Option {
+	return func(c *Client) {
+		c.ApiKeyId = apiKeyId
+	}
+}
+
+// WithSigner sets how requests are authenticated. By default the token is sent as a standard API key.
+func WithSigner(signer Signer) Option {
+	return func(c *Client) {
+		c.signer = signer
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried.
+func WithRetryPolicy(retryPolicy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = retryPolicy
+	}
+}
+
+// WithRateLimit limits the client to the given number of requests per second, allowing bursts of up to burst
+// requests. A rate of 0 or less disables the limit.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) {
+		if requestsPerSecond <= 0 {
+			c.limiter = nil
+			return
+		}
+
+		c.limiter = newRateLimiter(requestsPerSecond, burst)
+	}
+}
+
+// WithApiVersion pins the API version instead of detecting it.
+func WithApiVersion(apiVersion ApiVersion) Option {
+	return func(c *Client) {
+		c.apiVersion = apiVersion
+	}
 }
 
-type UsersResponse = []User
 type RolesResponse = []Role
+type APIKeysResponse = []APIKey
+
+func NewClient(httpClient *http.Client, token, apiUrl string, opts ...Option) *Client {
+	c := &Client{
+		httpClient:  httpClient,
+		Token:       token,
+		ApiUrl:      strings.TrimSuffix(apiUrl, "/"),
+		apiVersion:  ApiVersionAuto,
+		retryPolicy: DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.signer == nil {
+		c.signer = &StandardSigner{Key: token}
+	}
+
+	return c
+}
+
+// errStopStream stops a stream once the wanted element was found.
+var errStopStream = errors.New("xsoar: stop stream")
 
-func NewClient(httpClient *http.Client, token, apiUrl string) *Client {
-	return &Client{
-		httpClient: httpClient,
-		Token:      token,
-		ApiUrl:     apiUrl,
+// GetUser returns the user with the given ID, or nil if there is none.
+func (c *Client) GetUser(ctx context.Context, userId string) (*User, error) {
+	var found *User
+
+	err := c.StreamUsers(ctx, func(user User) error {
+		if user.Id != userId {
+			return nil
+		}
+
+		found = &user
+		return errStopStream
+	})
+	if err != nil && !errors.Is(err, errStopStream) {
+		return nil, err
 	}
+
+	return found, nil
 }
 
-func (c *Client) GetUsers(ctx context.Context) ([]User, error) {
-	var usersResponse UsersResponse
+// StreamUsers decodes the users one at a time and passes each of them to fn, so the full response is never held in
+// memory. Fields User does not declare, like preferences, are skipped while decoding. An error returned by fn stops
+// the stream and is returned as is.
+func (c *Client) StreamUsers(ctx context.Context, fn func(User) error) error {
+	return c.doStreamingRequest(
+		ctx,
+		http.MethodGet,
+		UsersEndpoint,
+		nil,
+		func(body io.Reader) error {
+			return decodeArray(body, fn)
+		},
+	)
+}
+
+func (c *Client) GetRoles(ctx context.Context) ([]Role, error) {
+	var rolesResponse RolesResponse
 
 	err := c.doRequest(
 		ctx,
 		http.MethodGet,
-		fmt.Sprintf(UsersBaseURL, c.ApiUrl),
-		&usersResponse,
+		RolesEndpoint,
+		&rolesResponse,
 		nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return usersResponse, nil
+	return rolesResponse, nil
 }
 
-func (c *Client) GetRoles(ctx context.Context) ([]Role, error) {
-	var rolesResponse RolesResponse
+// GetAPIKeys returns the API keys of every user. The key secrets are never returned.
+func (c *Client) GetAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var apiKeysResponse APIKeysResponse
 
 	err := c.doRequest(
 		ctx,
 		http.MethodGet,
-		fmt.Sprintf(RolesBaseURL, c.ApiUrl),
-		&rolesResponse,
+		APIKeysEndpoint,
+		&apiKeysResponse,
 		nil,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return rolesResponse, nil
+	return apiKeysResponse, nil
+}
+
+const (
+	apiKeyLength  = 32
+	apiKeyCharset = "0123456789ABCDEF"
+)
+
+// CreateAPIKeyBody creates an API key for the current user. Cortex XSOAR stores the key it is given, so the secret
+// is generated by the client.
+type CreateAPIKeyBody struct {
+	Name string `json:"name"`
+	Key  string `json:"apikey"`
+}
+
+// CreateAPIKey creates an API key for the current user and returns it along with its secret, which cannot be read
+// back later.
+func (c *Client) CreateAPIKey(ctx context.Context, name string) (*APIKey, string, error) {
+	secret, err := randomString(apiKeyLength, apiKeyCharset)
+	if err != nil {
+		return nil, "", fmt.Errorf("xsoar: failed to generate api key: %w", err)
+	}
+
+	var apiKeysResponse APIKeysResponse
+
+	err = c.doRequest(
+		ctx,
+		http.MethodPost,
+		APIKeysEndpoint,
+		&apiKeysResponse,
+		&CreateAPIKeyBody{
+			Name: name,
+			Key:  secret,
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the response lists every key of the user, the new one is the latest with the given name
+	var created *APIKey
+	for i := range apiKeysResponse {
+		key := &apiKeysResponse[i]
+		if key.Name == name && (created == nil || key.Created.After(created.Created)) {
+			created = key
+		}
+	}
+
+	if created == nil {
+		return nil, "", fmt.Errorf("xsoar: created api key %q missing from the response", name)
+	}
+
+	return created, secret, nil
+}
+
+// DeleteAPIKey deletes an API key, revoking it at once.
+func (c *Client) DeleteAPIKey(ctx context.Context, keyId string) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("%s/%s", APIKeysEndpoint, url.PathEscape(keyId)),
+		nil,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
@@ -77,7 +272,7 @@ func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
 	err := c.doRequest(
 		ctx,
 		http.MethodGet,
-		fmt.Sprintf(CurrentUserBaseURL, c.ApiUrl),
+		CurrentUserEndpoint,
 		&user,
 		nil,
 	)
@@ -88,29 +283,170 @@ func (c *Client) GetCurrentUser(ctx context.Context) (*User, error) {
 	return &user, nil
 }
 
-type Roles struct {
-	Roles []string `json:"roles"`
+// UpdateUserBody changes a user. Fields left empty are not changed. Version is the version the user was read at:
+// when the user changed since, XSOAR rejects the update and IsVersionConflict reports true for the error.
+type UpdateUserBody struct {
+	Id      string `json:"id"`
+	Version int    `json:"version"`
+	// Roles map every account of the user to role names, as in User.Roles. Accounts missing from the map may lose
+	// their roles, so callers send the user's complete map.
+	Roles    map[string][]string `json:"roles,omitempty"`
+	Disabled *bool               `json:"disabled,omitempty"`
+	// Password sets a new password, for local users only.
+	Password string `json:"password,omitempty"`
 }
 
-type UpdateRolesBody struct {
-	Id    string `json:"id"`
-	Roles Roles  `json:"roles"`
+func (c *Client) UpdateUser(ctx context.Context, data *UpdateUserBody) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodPost,
+		UpdateUserEndpoint,
+		nil,
+		data,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
-func (c *Client) UpdateUserRoles(ctx context.Context, userId string, roleIDs []string) error {
-	data := UpdateRolesBody{
-		Id: userId,
-		Roles: Roles{
-			Roles: roleIDs,
-		},
+// CreateUserBody creates a local user, which signs in with the given password.
+type CreateUserBody struct {
+	Username string              `json:"username"`
+	Name     string              `json:"name"`
+	Email    string              `json:"email"`
+	Password string              `json:"password"`
+	Roles    map[string][]string `json:"roles"`
+}
+
+// CreateUser creates a local user and returns it. Only Cortex XSOAR 6 has local users, XSOAR 8 users are invited.
+func (c *Client) CreateUser(ctx context.Context, data *CreateUserBody) (*User, error) {
+	var user User
+
+	err := c.doRequest(
+		ctx,
+		http.MethodPost,
+		CreateUserEndpoint,
+		&user,
+		data,
+	)
+	if err != nil {
+		return nil, err
 	}
 
+	return &user, nil
+}
+
+// InviteBody invites a user by email. The user chooses their password, or signs in through SSO, when accepting.
+type InviteBody struct {
+	Email string              `json:"email"`
+	Roles map[string][]string `json:"roles"`
+}
+
+// InviteUser sends an invitation and returns it. The user only exists once the invitation is accepted.
+func (c *Client) InviteUser(ctx context.Context, data *InviteBody) (*Invite, error) {
+	var invite Invite
+
 	err := c.doRequest(
 		ctx,
 		http.MethodPost,
-		fmt.Sprintf(UpdateUserBaseURL, c.ApiUrl),
+		InvitesEndpoint,
+		&invite,
+		data,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invite, nil
+}
+
+// DisableUser disables a user, who can no longer sign in. Their roles and history are kept.
+func (c *Client) DisableUser(ctx context.Context, userId string, version int) error {
+	disabled := true
+
+	return c.UpdateUser(ctx, &UpdateUserBody{
+		Id:       userId,
+		Version:  version,
+		Disabled: &disabled,
+	})
+}
+
+// EnableUser enables a disabled user.
+func (c *Client) EnableUser(ctx context.Context, userId string, version int) error {
+	disabled := false
+
+	return c.UpdateUser(ctx, &UpdateUserBody{
+		Id:       userId,
+		Version:  version,
+		Disabled: &disabled,
+	})
+}
+
+// SetUserPassword sets the password of a local user.
+func (c *Client) SetUserPassword(ctx context.Context, userId string, version int, password string) error {
+	return c.UpdateUser(ctx, &UpdateUserBody{
+		Id:       userId,
+		Version:  version,
+		Password: password,
+	})
+}
+
+// UpdateUserRoles replaces the roles of a user, see UpdateUserBody.
+func (c *Client) UpdateUserRoles(ctx context.Context, userId string, version int, roles map[string][]string) error {
+	return c.UpdateUser(ctx, &UpdateUserBody{
+		Id:      userId,
+		Version: version,
+		Roles:   roles,
+	})
+}
+
+// UpdateRole sends the role back with its changes. The role should be one read from GetRoles, so its version and the
+// fields Role does not declare are sent along: when the role changed since it was read, XSOAR rejects the update and
+// IsVersionConflict reports true for the error.
+func (c *Client) UpdateRole(ctx context.Context, role *Role) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodPost,
+		UpdateRoleEndpoint,
+		nil,
+		role,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateRole creates a role with the name and permissions of the given role, which has no ID yet, and returns the
+// created role.
+func (c *Client) CreateRole(ctx context.Context, role *Role) (*Role, error) {
+	var created Role
+
+	err := c.doRequest(
+		ctx,
+		http.MethodPost,
+		UpdateRoleEndpoint,
+		&created,
+		role,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// DeleteRole deletes a role. Users holding the role should be moved to other roles first.
+func (c *Client) DeleteRole(ctx context.Context, roleId string) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("%s/%s", RolesEndpoint, url.PathEscape(roleId)),
+		nil,
 		nil,
-		&data,
 	)
 	if err != nil {
 		return err
@@ -119,52 +455,292 @@ func (c *Client) UpdateUserRoles(ctx context.Context, userId string, roleIDs []s
 	return nil
 }
 
+// ApiVersion returns the API version the client talks to, detecting it first if needed.
+func (c *Client) ApiVersion(ctx context.Context) (ApiVersion, error) {
+	if _, err := c.baseURL(ctx); err != nil {
+		return "", err
+	}
+
+	return c.apiVersion, nil
+}
+
+// baseURL returns the API URL including the path prefix of the detected API version.
+// Detection runs once; a failed detection is retried on the next request.
+func (c *Client) baseURL(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resolved {
+		return c.ApiUrl + c.pathPrefix, nil
+	}
+
+	switch c.apiVersion {
+	case ApiVersion6:
+		c.pathPrefix = ""
+
+	case ApiVersion8:
+		prefix, err := c.detectPathPrefix(ctx, v8PathPrefixes)
+		if err != nil {
+			return "", err
+		}
+		c.pathPrefix = prefix
+
+	default:
+		// XSOAR 6 has no notion of API key IDs, so without one there is nothing to detect.
+		if c.ApiKeyId == "" {
+			c.apiVersion = ApiVersion6
+			c.pathPrefix = ""
+			break
+		}
+
+		prefix, err := c.detectPathPrefix(ctx, append(v8PathPrefixes, ""))
+		if err != nil {
+			return "", err
+		}
+		c.apiVersion = ApiVersion8
+		c.pathPrefix = prefix
+	}
+
+	c.resolved = true
+
+	return c.ApiUrl + c.pathPrefix, nil
+}
+
+// detectPathPrefix returns the first of the given prefixes under which the server serves the current user endpoint.
+// A prefix matches when the endpoint answers with JSON, or rejects the credentials with 401 or 403. The probes are
+// retried like any other request; when one still fails with another status, like 429 or 5xx, detection fails and runs
+// again on the next request rather than settling on a prefix the server never confirmed.
+func (c *Client) detectPathPrefix(ctx context.Context, prefixes []string) (string, error) {
+	// The API URL may already include the prefix, e.g. `https://api-tenant.xdr.us.paloaltonetworks.com/xsoar`.
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasSuffix(c.ApiUrl, prefix) {
+			return "", nil
+		}
+	}
+
+	for _, prefix := range prefixes {
+		rawResponse, err := c.send(ctx, http.MethodGet, c.ApiUrl+prefix, CurrentUserEndpoint, nil, true)
+		if err != nil {
+			var apiErr *ApiError
+			if !errors.As(err, &apiErr) {
+				return "", err
+			}
+
+			switch apiErr.StatusCode {
+			case http.StatusNotFound:
+				continue
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return prefix, nil
+			default:
+				return "", fmt.Errorf("xsoar: unable to detect the API path of %s: %w", c.ApiUrl, err)
+			}
+		}
+
+		// a web UI served under the prefix answers with an HTML page instead
+		isJSON := json.NewDecoder(rawResponse.Body).Decode(&json.RawMessage{}) == nil
+		_, _ = io.Copy(io.Discard, rawResponse.Body)
+		rawResponse.Body.Close()
+
+		if isJSON {
+			return prefix, nil
+		}
+	}
+
+	return "", fmt.Errorf("xsoar: unable to detect the API path of %s", c.ApiUrl)
+}
+
+func (c *Client) setHeaders(req *http.Request) error {
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if c.ApiKeyId != "" && c.apiVersion != ApiVersion6 {
+		req.Header.Set("x-xdr-auth-id", c.ApiKeyId)
+	}
+
+	return c.signer.Sign(req)
+}
+
+// doRequest sends a request to the given endpoint and decodes the response into resourceResponse.
 func (c *Client) doRequest(
 	ctx context.Context,
 	method string,
-	urlAddress string,
+	endpoint string,
+	resourceResponse interface{},
+	data interface{},
+) error {
+	return c.doStreamingRequest(ctx, method, endpoint, data, decodeInto(resourceResponse))
+}
+
+// doSearchRequest posts a read-only query, such as an incident search, to the given endpoint and decodes the response
+// into resourceResponse. Replaying the query changes nothing, so it is retried like a GET.
+func (c *Client) doSearchRequest(
+	ctx context.Context,
+	endpoint string,
 	resourceResponse interface{},
 	data interface{},
 ) error {
-	var body io.Reader
+	return c.do(ctx, http.MethodPost, endpoint, data, true, decodeInto(resourceResponse))
+}
+
+// decodeInto returns a decode function storing a JSON response in resourceResponse.
+func decodeInto(resourceResponse interface{}) func(body io.Reader) error {
+	return func(body io.Reader) error {
+		err := json.NewDecoder(body).Decode(&resourceResponse)
+
+		// Some updates respond without a body, which is fine when none is expected.
+		if resourceResponse == nil && errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		return err
+	}
+}
+
+// doStreamingRequest sends a request to the given endpoint and hands the body of a successful response to decode.
+// Idempotent requests are retried on transport errors and on throttling or gateway failures; others only when
+// throttled. Retries happen before decode is called, so decode runs at most once.
+func (c *Client) doStreamingRequest(
+	ctx context.Context,
+	method string,
+	endpoint string,
+	data interface{},
+	decode func(body io.Reader) error,
+) error {
+	return c.do(ctx, method, endpoint, data, isIdempotent(method), decode)
+}
+
+// do sends a request like doStreamingRequest, retrying it as an idempotent request or not as told.
+func (c *Client) do(
+	ctx context.Context,
+	method string,
+	endpoint string,
+	data interface{},
+	idempotent bool,
+	decode func(body io.Reader) error,
+) error {
+	var jsonBody []byte
 
 	if data != nil {
-		jsonBody, err := json.Marshal(data)
+		var err error
+		jsonBody, err = json.Marshal(data)
 		if err != nil {
 			return err
 		}
+	}
 
-		body = bytes.NewBuffer(jsonBody)
+	baseURL, err := c.baseURL(ctx)
+	if err != nil {
+		return err
 	}
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		method,
-		urlAddress,
-		body,
-	)
+	rawResponse, err := c.send(ctx, method, baseURL, endpoint, jsonBody, idempotent)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("Authorization", c.Token)
-	req.Header.Set("Accept", "application/json")
+	err = decode(rawResponse.Body)
+	rawResponse.Body.Close()
 
-	rawResponse, err := c.httpClient.Do(req)
+	return err
+}
+
+// send sends a request to the given endpoint under baseURL, retrying as described on doStreamingRequest, and returns
+// the successful response, whose body the caller closes. Unsuccessful responses are returned as an ApiError.
+func (c *Client) send(
+	ctx context.Context,
+	method string,
+	baseURL string,
+	endpoint string,
+	jsonBody []byte,
+	idempotent bool,
+) (*http.Response, error) {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		if err := c.throttle(ctx, endpoint); err != nil {
+			return nil, err
+		}
+
+		var body io.Reader
+		if jsonBody != nil {
+			body = bytes.NewReader(jsonBody)
+		}
+
+		req, err := http.NewRequestWithContext(
+			ctx,
+			method,
+			baseURL+endpoint,
+			body,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = c.setHeaders(req)
+		if err != nil {
+			return nil, err
+		}
+
+		rawResponse, err := c.httpClient.Do(req)
+		if err != nil {
+			if idempotent && ctx.Err() == nil && c.waitForRetry(ctx, endpoint, attempt, start, 0, err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if rawResponse.StatusCode >= 300 {
+			apiErr := newApiError(method, endpoint, rawResponse)
+			rawResponse.Body.Close()
+
+			retryAfter := parseRetryAfter(rawResponse.Header)
+			if rawResponse.StatusCode == http.StatusTooManyRequests {
+				c.markThrottled(time.Now().Add(retryAfter))
+			}
+
+			if isRetryableStatus(rawResponse.StatusCode, idempotent) &&
+				c.waitForRetry(ctx, endpoint, attempt, start, retryAfter, apiErr) {
+				continue
+			}
+
+			return nil, apiErr
+		}
+
+		return rawResponse, nil
+	}
+}
+
+// decodeArray walks a JSON array one element at a time, passing each decoded element to fn.
+func decodeArray[T any](body io.Reader, fn func(T) error) error {
+	decoder := json.NewDecoder(body)
+
+	token, err := decoder.Token()
 	if err != nil {
 		return err
 	}
 
-	defer rawResponse.Body.Close()
+	// XSOAR responds with null instead of an empty array when there is nothing to list.
+	if token == nil {
+		return nil
+	}
 
-	if rawResponse.StatusCode >= 300 {
-		return status.Error(codes.Code(rawResponse.StatusCode), "Request failed")
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("xsoar: expected a JSON array, got %v", token)
 	}
 
-	if err := json.NewDecoder(rawResponse.Body).Decode(&resourceResponse); err != nil {
-		return err
+	for decoder.More() {
+		var element T
+		if err := decoder.Decode(&element); err != nil {
+			return err
+		}
+
+		if err := fn(element); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	_, err = decoder.Token()
+
+	return err
 }
@@ -0,0 +1,135 @@
+package xsoar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxErrorBodySize caps how much of an error response is read.
+const maxErrorBodySize = 64 * 1024
+
+// requestIdHeaders lists the response headers the server may report the request ID in.
+var requestIdHeaders = []string{"X-Request-Id", "X-Xdr-Request-Id"}
+
+// ApiError is returned when the Cortex XSOAR API responds with an unsuccessful status code.
+type ApiError struct {
+	StatusCode int
+	// ErrorId and Message are taken from the error body XSOAR sends, when there is one.
+	ErrorId   string
+	Message   string
+	Method    string
+	Endpoint  string
+	RequestId string
+}
+
+// errorBody is the error document XSOAR responds with, e.g.
+// `{"id":"forbidden","status":403,"title":"Forbidden","detail":"...","error":"..."}`.
+type errorBody struct {
+	Id     string `json:"id"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	Error  string `json:"error"`
+}
+
+func newApiError(method, endpoint string, resp *http.Response) *ApiError {
+	apiErr := &ApiError{
+		StatusCode: resp.StatusCode,
+		Method:     method,
+		Endpoint:   endpoint,
+	}
+
+	for _, header := range requestIdHeaders {
+		if requestId := resp.Header.Get(header); requestId != "" {
+			apiErr.RequestId = requestId
+			break
+		}
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil || len(raw) == 0 {
+		return apiErr
+	}
+
+	var body errorBody
+	if err := json.Unmarshal(raw, &body); err != nil {
+		apiErr.Message = strings.TrimSpace(string(raw))
+		return apiErr
+	}
+
+	apiErr.ErrorId = body.Id
+	for _, message := range []string{body.Detail, body.Error, body.Title} {
+		if message != "" {
+			apiErr.Message = message
+			break
+		}
+	}
+
+	return apiErr
+}
+
+func (e *ApiError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "xsoar: %s %s: %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.Message != "" {
+		fmt.Fprintf(&sb, ": %s", e.Message)
+	}
+	if e.ErrorId != "" {
+		fmt.Fprintf(&sb, " (error id: %s)", e.ErrorId)
+	}
+	if e.RequestId != "" {
+		fmt.Fprintf(&sb, " (request id: %s)", e.RequestId)
+	}
+
+	return sb.String()
+}
+
+// Code maps the HTTP status code to a gRPC code.
+func (e *ApiError) Code() codes.Code {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return codes.InvalidArgument
+	case e.StatusCode == http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case e.StatusCode == http.StatusForbidden:
+		return codes.PermissionDenied
+	case e.StatusCode == http.StatusNotFound:
+		return codes.NotFound
+	case e.StatusCode == http.StatusConflict:
+		return codes.Aborted
+	case e.StatusCode == http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case e.StatusCode >= 500:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// GRPCStatus lets the gRPC status package, and so the SDK, see the mapped code.
+func (e *ApiError) GRPCStatus() *status.Status {
+	return status.New(e.Code(), e.Error())
+}
+
+// IsVersionConflict reports whether err is XSOAR rejecting an update made against an outdated version of an object.
+func IsVersionConflict(err error) bool {
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == http.StatusConflict {
+		return true
+	}
+
+	// older servers report conflicts as bad requests, e.g. `{"id":"bad_request","error":"version mismatch..."}`
+	return apiErr.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "version")
+}
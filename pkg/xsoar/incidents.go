@@ -0,0 +1,185 @@
+package xsoar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	SearchIncidentsEndpoint = "/incidents/search"
+	BatchUpdateEndpoint     = "/incident/batchUpdate"
+	SearchTasksEndpoint     = "/tasks/search"
+	AssignTaskEndpoint      = "/inv-playbook/task/assign"
+
+	// SearchPageSize is the number of incidents or tasks requested per search page.
+	SearchPageSize = 100
+)
+
+type Incident struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Owner  string `json:"owner"`
+	Status int    `json:"status"`
+}
+
+// Task is a playbook task of an incident.
+type Task struct {
+	Id         string `json:"id"`
+	IncidentId string `json:"incidentId"`
+	Name       string `json:"name"`
+	Assignee   string `json:"assignee"`
+}
+
+type SearchFilter struct {
+	Query string `json:"query,omitempty"`
+	Page  int    `json:"page"`
+	Size  int    `json:"size"`
+}
+
+type SearchIncidentsBody struct {
+	Filter SearchFilter `json:"filter"`
+}
+
+type SearchIncidentsResponse struct {
+	Total int        `json:"total"`
+	Data  []Incident `json:"data"`
+}
+
+type SearchTasksResponse struct {
+	Total int    `json:"total"`
+	Data  []Task `json:"data"`
+}
+
+// quoteQuery quotes a value for the Cortex XSOAR query language.
+func quoteQuery(value string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// SearchIncidents returns every incident matching the query, fetching one page of SearchPageSize at a time. Searches
+// are read-only, so failed pages are retried like GET requests.
+func (c *Client) SearchIncidents(ctx context.Context, query string) ([]Incident, error) {
+	var incidents []Incident
+
+	for page := 0; ; page++ {
+		var response SearchIncidentsResponse
+
+		err := c.doSearchRequest(
+			ctx,
+			SearchIncidentsEndpoint,
+			&response,
+			&SearchIncidentsBody{
+				Filter: SearchFilter{Query: query, Page: page, Size: SearchPageSize},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		incidents = append(incidents, response.Data...)
+
+		if len(response.Data) < SearchPageSize || len(incidents) >= response.Total {
+			return incidents, nil
+		}
+	}
+}
+
+// GetIncidentsOwnedBy returns the incidents owned by the user with the given username.
+func (c *Client) GetIncidentsOwnedBy(ctx context.Context, username string) ([]Incident, error) {
+	return c.SearchIncidents(ctx, "owner:"+quoteQuery(username))
+}
+
+// GetTasksAssignedTo returns the playbook tasks assigned to the user with the given username, in any incident.
+func (c *Client) GetTasksAssignedTo(ctx context.Context, username string) ([]Task, error) {
+	var tasks []Task
+
+	for page := 0; ; page++ {
+		var response SearchTasksResponse
+
+		err := c.doSearchRequest(
+			ctx,
+			SearchTasksEndpoint,
+			&response,
+			&SearchIncidentsBody{
+				Filter: SearchFilter{Query: "assignee:" + quoteQuery(username), Page: page, Size: SearchPageSize},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, response.Data...)
+
+		if len(response.Data) < SearchPageSize || len(tasks) >= response.Total {
+			return tasks, nil
+		}
+	}
+}
+
+type BatchUpdateIncidentsBody struct {
+	Ids   []string `json:"ids"`
+	Owner string   `json:"owner"`
+}
+
+// SetIncidentsOwner makes the user with the given username the owner of the incidents.
+func (c *Client) SetIncidentsOwner(ctx context.Context, incidentIds []string, username string) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodPost,
+		BatchUpdateEndpoint,
+		nil,
+		&BatchUpdateIncidentsBody{
+			Ids:   incidentIds,
+			Owner: username,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type AssignTaskBody struct {
+	IncidentId string `json:"invId"`
+	TaskId     string `json:"inTaskID"`
+	Assignee   string `json:"assignee"`
+}
+
+// AssignTask assigns a playbook task to the user with the given username.
+func (c *Client) AssignTask(ctx context.Context, task *Task, username string) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodPost,
+		AssignTaskEndpoint,
+		nil,
+		&AssignTaskBody{
+			IncidentId: task.IncidentId,
+			TaskId:     task.Id,
+			Assignee:   username,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUser deletes a user. Incidents and tasks of the user are not reassigned.
+func (c *Client) DeleteUser(ctx context.Context, userId string) error {
+	err := c.doRequest(
+		ctx,
+		http.MethodDelete,
+		fmt.Sprintf("%s/%s", UsersEndpoint, url.PathEscape(userId)),
+		nil,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,5 +1,17 @@
 package xsoar
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// MainAccount is the key of the main tenant in User.Roles.
+	MainAccount = "demisto"
+	// AccountPrefix prefixes the keys of MSSP child accounts in User.Roles, e.g. `acc_tenant-a`.
+	AccountPrefix = "acc_"
+)
+
 type BaseResource struct {
 	Id      string `json:"id"`
 	Version int    `json:"version"`
@@ -13,14 +25,89 @@ type User struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
 
-	Email string              `json:"email"`
+	Email string `json:"email"`
+	// Roles maps each account the user has access to, MainAccount or an MSSP child account, to role names.
 	Roles map[string][]string `json:"roles"`
 
 	Disabled bool `json:"disabled"`
+	// Manager is the username of the user's manager, only set when the profile has one.
+	Manager string `json:"manager,omitempty"`
+	// AuthType tells how the user signs in, AuthTypeLocal or through SSO or LDAP. Older versions leave it empty.
+	AuthType string `json:"authType,omitempty"`
+}
+
+const AuthTypeLocal = "local"
+
+// IsLocal reports whether the user signs in with a password stored by Cortex XSOAR. Users whose sign in is not
+// reported may be SSO or LDAP users, and are not local.
+func (u *User) IsLocal() bool {
+	return u.AuthType == AuthTypeLocal
 }
 
+// Role keeps the fields it does not declare, such as page access settings, so a role read from the API can be sent
+// back to UpdateRole without losing them.
 type Role struct {
 	BaseResource
 	Name        string   `json:"name"`
 	Permissions []string `json:"permissions"`
+
+	extra map[string]json.RawMessage
+}
+
+// roleFields is Role without its JSON methods.
+type roleFields Role
+
+func (r *Role) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, (*roleFields)(r))
+	if err != nil {
+		return err
+	}
+
+	extra := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &extra)
+	if err != nil {
+		return err
+	}
+
+	for _, declared := range []string{"id", "version", "name", "permissions"} {
+		delete(extra, declared)
+	}
+	r.extra = extra
+
+	return nil
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(roleFields(r))
+	if err != nil || len(r.extra) == 0 {
+		return data, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(r.extra)+4)
+	for key, value := range r.extra {
+		fields[key] = value
+	}
+
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+type APIKey struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Username is the user who created the key, requests made with the key act with their roles.
+	Username string    `json:"username"`
+	Created  time.Time `json:"created"`
+	// LastUsed is only reported by some Cortex XSOAR versions.
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+type Invite struct {
+	Id    string              `json:"id"`
+	Email string              `json:"email"`
+	Roles map[string][]string `json:"roles"`
 }
@@ -0,0 +1,160 @@
+package xsoar
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// rateLimiter is a token bucket holding up to burst tokens, refilled at rate tokens per second.
+// Every request takes one token; when none is left the request waits until one is refilled.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens accumulated since the last call. It must be called with the lock held.
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.tokens--
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that was not used.
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+}
+
+// remaining returns the number of requests that can be sent right away and when the bucket is full again.
+func (l *rateLimiter) remaining() (int64, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	tokens := int64(l.tokens)
+	if tokens < 0 {
+		tokens = 0
+	}
+
+	return tokens, now.Add(time.Duration((l.burst - l.tokens) / l.rate * float64(time.Second)))
+}
+
+// RateLimitStatus describes the client's request budget.
+type RateLimitStatus struct {
+	// Throttled is set when a request was delayed, either by the client's own limit or by the server, since the
+	// status was last read.
+	Throttled bool
+	// Limit is the burst size of the client's own limit, 0 if there is none.
+	Limit     int64
+	Remaining int64
+	// ResetAt is when the budget is fully available again.
+	ResetAt time.Time
+}
+
+// RateLimitStatus returns the current request budget and clears the throttled flag.
+func (c *Client) RateLimitStatus() RateLimitStatus {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+
+	rateLimitStatus := RateLimitStatus{
+		Throttled: c.throttled,
+		ResetAt:   c.throttledUntil,
+	}
+
+	if c.limiter != nil {
+		remaining, resetAt := c.limiter.remaining()
+		rateLimitStatus.Limit = int64(c.limiter.burst)
+		rateLimitStatus.Remaining = remaining
+		if resetAt.After(rateLimitStatus.ResetAt) {
+			rateLimitStatus.ResetAt = resetAt
+		}
+	}
+
+	c.throttled = false
+	c.throttledUntil = time.Time{}
+
+	return rateLimitStatus
+}
+
+// markThrottled records that a request had to wait until the given time.
+func (c *Client) markThrottled(until time.Time) {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+
+	c.throttled = true
+	if until.After(c.throttledUntil) {
+		c.throttledUntil = until
+	}
+}
+
+// throttle blocks until the client's own rate limit allows another request.
+func (c *Client) throttle(ctx context.Context, endpoint string) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	delay := c.limiter.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	c.markThrottled(time.Now().Add(delay))
+
+	ctxzap.Extract(ctx).Info(
+		"xsoar: request delayed by rate limit",
+		zap.String("endpoint", endpoint),
+		zap.Duration("delay", delay),
+	)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		c.limiter.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package xsoar
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRetryMaxAttempts = 5
+	DefaultRetryMaxElapsed  = 2 * time.Minute
+
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy bounds how often and for how long a failed request is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// MaxElapsed is the time budget for all attempts of a request, including the waits between them.
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy is used unless the client is created with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: DefaultRetryMaxAttempts,
+	MaxElapsed:  DefaultRetryMaxElapsed,
+}
+
+// isIdempotent reports whether a request with the given method can be replayed without side effects.
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// isRetryableStatus reports whether a response status is worth retrying. Requests which are not idempotent are only
+// retried when the server explicitly rejected them with 429, as any other failure may have been applied already.
+func isRetryableStatus(statusCode int, idempotent bool) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+// backoff returns an exponential delay with full jitter for the given attempt, starting at 1.
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay))) // #nosec G404 -- jitter does not need a CSPRNG.
+}
+
+// waitForRetry sleeps before the next attempt and reports whether the request should be retried at all. It gives up
+// once the attempts or the time budget are exhausted, or when the context is done.
+func (c *Client) waitForRetry(ctx context.Context, endpoint string, attempt int, start time.Time, retryAfter time.Duration, cause error) bool {
+	if attempt >= c.retryPolicy.MaxAttempts {
+		return false
+	}
+
+	delay := backoff(attempt)
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	if c.retryPolicy.MaxElapsed > 0 && time.Since(start)+delay > c.retryPolicy.MaxElapsed {
+		return false
+	}
+
+	ctxzap.Extract(ctx).Warn(
+		"xsoar: retrying request",
+		zap.String("endpoint", endpoint),
+		zap.Int("attempt", attempt),
+		zap.Duration("delay", delay),
+		zap.Error(cause),
+	)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
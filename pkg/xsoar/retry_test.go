@@ -0,0 +1,172 @@
+package xsoar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{name: "missing", value: "", min: 0, max: 0},
+		{name: "seconds", value: "7", min: 7 * time.Second, max: 7 * time.Second},
+		{name: "zero seconds", value: "0", min: 0, max: 0},
+		{name: "negative seconds", value: "-3", min: 0, max: 0},
+		{name: "http date", value: time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), min: 28 * time.Second, max: 30 * time.Second},
+		{name: "http date in the past", value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), min: 0, max: 0},
+		{name: "garbage", value: "soon", min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+
+			got := parseRetryAfter(header)
+			if got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: retryBaseDelay},
+		{attempt: 2, max: 2 * retryBaseDelay},
+		{attempt: 3, max: 4 * retryBaseDelay},
+		{attempt: 20, max: retryMaxDelay},
+		{attempt: 100, max: retryMaxDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := backoff(tt.attempt); got < 0 || got >= tt.max {
+					t.Fatalf("backoff(%d) = %s, want in [0, %s)", tt.attempt, got, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		idempotent bool
+		want       bool
+	}{
+		{statusCode: http.StatusTooManyRequests, idempotent: true, want: true},
+		{statusCode: http.StatusTooManyRequests, idempotent: false, want: true},
+		{statusCode: http.StatusBadGateway, idempotent: true, want: true},
+		{statusCode: http.StatusBadGateway, idempotent: false, want: false},
+		{statusCode: http.StatusServiceUnavailable, idempotent: true, want: true},
+		{statusCode: http.StatusServiceUnavailable, idempotent: false, want: false},
+		{statusCode: http.StatusGatewayTimeout, idempotent: true, want: true},
+		{statusCode: http.StatusGatewayTimeout, idempotent: false, want: false},
+		{statusCode: http.StatusInternalServerError, idempotent: true, want: false},
+		{statusCode: http.StatusBadRequest, idempotent: true, want: false},
+		{statusCode: http.StatusConflict, idempotent: false, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryableStatus(tt.statusCode, tt.idempotent); got != tt.want {
+			t.Errorf("isRetryableStatus(%d, %t) = %t, want %t", tt.statusCode, tt.idempotent, got, tt.want)
+		}
+	}
+}
+
+// newRetryTestClient returns a client for a server answering every request with the given statuses in turn, and
+// 200 once they run out, along with the number of requests the server got.
+func newRetryTestClient(t *testing.T, policy RetryPolicy, header http.Header, statuses ...int) (*Client, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+
+		if n <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Client(), "token", server.URL, WithApiVersion(ApiVersion6), WithRetryPolicy(policy))
+
+	return client, &requests
+}
+
+func TestRequestRetries(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, MaxElapsed: time.Minute}
+
+	tests := []struct {
+		name     string
+		method   string
+		search   bool
+		policy   RetryPolicy
+		header   http.Header
+		statuses []int
+		wantErr  bool
+		wantReqs int32
+	}{
+		{name: "get retried on 503", method: http.MethodGet, policy: policy, statuses: []int{503}, wantReqs: 2},
+		{name: "get retried on 429", method: http.MethodGet, policy: policy, statuses: []int{429, 429}, wantReqs: 3},
+		{name: "get gives up after max attempts", method: http.MethodGet, policy: policy, statuses: []int{502, 502, 502}, wantErr: true, wantReqs: 3},
+		{name: "get not retried on 500", method: http.MethodGet, policy: policy, statuses: []int{500}, wantErr: true, wantReqs: 1},
+		{name: "post retried on 429", method: http.MethodPost, policy: policy, statuses: []int{429}, wantReqs: 2},
+		{name: "post not retried on 503", method: http.MethodPost, policy: policy, statuses: []int{503}, wantErr: true, wantReqs: 1},
+		{name: "post not retried on 502", method: http.MethodPost, policy: policy, statuses: []int{502}, wantErr: true, wantReqs: 1},
+		{name: "search retried on 503", method: http.MethodPost, search: true, policy: policy, statuses: []int{503, 502}, wantReqs: 3},
+		{name: "search not retried on 500", method: http.MethodPost, search: true, policy: policy, statuses: []int{500}, wantErr: true, wantReqs: 1},
+		{name: "retries disabled", method: http.MethodGet, policy: RetryPolicy{MaxAttempts: 1}, statuses: []int{503}, wantErr: true, wantReqs: 1},
+		{
+			name:     "retry after beyond time budget",
+			method:   http.MethodGet,
+			policy:   RetryPolicy{MaxAttempts: 3, MaxElapsed: time.Second},
+			header:   http.Header{"Retry-After": []string{"5"}},
+			statuses: []int{429},
+			wantErr:  true,
+			wantReqs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newRetryTestClient(t, tt.policy, tt.header, tt.statuses...)
+
+			var err error
+			if tt.search {
+				err = client.doSearchRequest(context.Background(), SearchIncidentsEndpoint, nil, &SearchIncidentsBody{})
+			} else {
+				err = client.doRequest(context.Background(), tt.method, RolesEndpoint, nil, nil)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("doRequest() error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if got := atomic.LoadInt32(requests); got != tt.wantReqs {
+				t.Errorf("server got %d requests, want %d", got, tt.wantReqs)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package xsoar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ApiKeyType is the security level of a Cortex API key.
+type ApiKeyType string
+
+const (
+	// ApiKeyTypeStandard keys are sent as is in the Authorization header.
+	ApiKeyTypeStandard ApiKeyType = "standard"
+	// ApiKeyTypeAdvanced keys are never sent; each request carries a hash of the key, a nonce and a timestamp instead.
+	ApiKeyTypeAdvanced ApiKeyType = "advanced"
+)
+
+const (
+	nonceLength  = 64
+	nonceCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Signer authenticates a request sent to the Cortex XSOAR API.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// NewSigner returns the signer for the given API key type.
+func NewSigner(keyType ApiKeyType, key string) (Signer, error) {
+	switch keyType {
+	case ApiKeyTypeStandard, "":
+		return &StandardSigner{Key: key}, nil
+	case ApiKeyTypeAdvanced:
+		return &AdvancedSigner{Key: key}, nil
+	default:
+		return nil, fmt.Errorf("xsoar: unknown API key type %q", keyType)
+	}
+}
+
+// StandardSigner sends the API key in the Authorization header.
+type StandardSigner struct {
+	Key string
+}
+
+func (s *StandardSigner) Sign(req *http.Request) error {
+	req.Header.Set("Authorization", s.Key)
+
+	return nil
+}
+
+// AdvancedSigner sends a SHA-256 hash of the API key, a random nonce and the current timestamp in milliseconds.
+type AdvancedSigner struct {
+	Key string
+}
+
+func (s *AdvancedSigner) Sign(req *http.Request) error {
+	nonce, err := randomString(nonceLength, nonceCharset)
+	if err != nil {
+		return fmt.Errorf("xsoar: failed to generate nonce: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	hash := sha256.Sum256([]byte(s.Key + nonce + timestamp))
+
+	req.Header.Set("x-xdr-timestamp", timestamp)
+	req.Header.Set("x-xdr-nonce", nonce)
+	req.Header.Set("Authorization", hex.EncodeToString(hash[:]))
+
+	return nil
+}
+
+// randomString returns a string of the given length drawn uniformly from charset using a CSPRNG.
+func randomString(length int, charset string) (string, error) {
+	out := make([]byte, length)
+	limit := big.NewInt(int64(len(charset)))
+
+	for i := range out {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+
+		out[i] = charset[n.Int64()]
+	}
+
+	return string(out), nil
+}